@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,7 +13,7 @@ import (
 )
 
 type Flags struct {
-	Net  script.Network
+	Net  *script.NetParams
 	Path string
 }
 
@@ -26,41 +27,101 @@ func newAddress(ctx *cli.Context) error {
 	}
 	desc := ctx.Args()[0]
 
-	var net script.Network
-	switch ctx.String("network") {
-	case "mainnet":
-		net = script.Mainnet
-	case "testnet":
-		net = script.Testnet
-	case "regtest":
-		net = script.Regtest
-	default:
+	net, err := script.NetworkByName(ctx.String("network"))
+	if err != nil {
 		return fmt.Errorf("net '%s' is invalid", ctx.String("network"))
 	}
 
-	basePath := "m/"
-	// change addresses uses 1 in the path
-	if !ctx.Bool("change") {
-		basePath += "0"
+	w, err := wallet.NewWallet(desc, net)
+	if err != nil {
+		return err
+	}
+
+	chains, err := w.Chains()
+	if err != nil {
+		return err
+	}
+
+	basePath := "m"
+	if len(chains) > 1 {
+		// multipath descriptors define their own receive and change chains
+		w = chains[0]
+		if ctx.Bool("change") {
+			w = chains[1]
+		}
+	} else if !ctx.Bool("change") {
+		// change addresses uses 1 in the path
+		basePath += "/0"
 	} else {
-		basePath += "1"
+		basePath += "/1"
 	}
 	offset := ctx.Uint("offset")
 
 	for i := uint(0); i < ctx.Uint("num"); i++ {
-		w, err := wallet.NewWallet(desc, net)
-		if err != nil {
-			return err
-		}
 		path := fmt.Sprintf("%s/%d", basePath, offset+i)
-		w, err = w.Path(path)
+		w, err := w.Path(path)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("%s: %s\n", path, w.Address())
+		addrs := w.Addresses()
+		if len(addrs) == 0 {
+			return script.ErrNoAddress
+		}
+
+		for _, addr := range addrs {
+			fmt.Printf("%s: %s\n", path, addr)
+		}
+	}
+
+	return nil
+}
+
+func checksum(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return errors.New("`checksum` requires exactly 1 argument")
+	}
+
+	desc, err := script.AddChecksum(ctx.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(desc)
+	return nil
+}
+
+func compile(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return errors.New("`compile` requires exactly 1 argument")
+	}
+
+	c, err := script.CompilePolicy(ctx.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(c.Descriptor())
+	fmt.Printf("script size: %d, expected witness size: %.2f\n", c.ScriptSize, c.WitnessSize)
+	return nil
+}
+
+func decodeScript(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return errors.New("`decodescript` requires exactly 1 argument")
+	}
+
+	b, err := hex.DecodeString(ctx.Args()[0])
+	if err != nil {
+		return fmt.Errorf("invalid script hex: %w", err)
+	}
+
+	asm, err := script.Disassemble(b)
+	if err != nil {
+		return err
 	}
 
+	fmt.Println(asm)
 	return nil
 }
 
@@ -74,13 +135,34 @@ func main() {
 				ArgsUsage:   "<descriptor>",
 				Description: "`newaddrs` generates address given a descriptor.",
 				Flags: []cli.Flag{
-					cli.StringFlag{Name: "network", Usage: "Sets the Bitcoin network [mainnet|testnet|regtest]", Value: "mainnet"},
+					cli.StringFlag{Name: "network", Usage: "Sets the Bitcoin network [mainnet|testnet|signet|regtest]", Value: "mainnet"},
 					cli.UintFlag{Name: "num", Usage: "How many addresses to generate", Value: 10},
 					cli.UintFlag{Name: "offset"},
 					cli.BoolFlag{Name: "change", Usage: "Generate a change address"},
 				},
 				Action: newAddress,
 			},
+			{
+				Name:        "checksum",
+				Usage:       "Computes a descriptor checksum",
+				ArgsUsage:   "<descriptor>",
+				Description: "`checksum` prints the descriptor with its checksum appended, verifying it if already present.",
+				Action:      checksum,
+			},
+			{
+				Name:        "compile",
+				Usage:       "Compiles a policy into a descriptor",
+				ArgsUsage:   "<policy>",
+				Description: "`compile` prints the wsh() descriptor with the cheapest miniscript implementing the policy.",
+				Action:      compile,
+			},
+			{
+				Name:        "decodescript",
+				Usage:       "Disassembles a hex encoded script",
+				ArgsUsage:   "<hex>",
+				Description: "`decodescript` prints the script in the same ASM format as Bitcoin Core.",
+				Action:      decodeScript,
+			},
 		},
 	}).RunAndExitOnError()
 }
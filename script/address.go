@@ -0,0 +1,190 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// validateWitnessProgram checks a witness version and program combination as
+// described in BIP141 and BIP350.
+func validateWitnessProgram(version byte, program []byte) error {
+	if version > 16 {
+		return fmt.Errorf("invalid witness version %d", version)
+	}
+
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("invalid witness program length %d", len(program))
+	}
+
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("invalid witness v0 program length %d", len(program))
+	}
+
+	return nil
+}
+
+// witnessEncoding returns the checksum encoding used by a witness version:
+// bech32 for v0 and bech32m for v1 and above.
+func witnessEncoding(version byte) bech32Encoding {
+	if version == 0 {
+		return encBech32
+	}
+	return encBech32m
+}
+
+// encodeSegWitAddress encodes a witness program into a segwit address.
+func encodeSegWitAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := validateWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+
+	// Group the program bytes into 5 bit groups, as this is what is used to
+	// encode each character in the address string.
+	converted, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32Encode(hrp,
+		NewBytes([]byte{version}, converted),
+		witnessEncoding(version),
+	), nil
+}
+
+// decodeSegWitAddress decodes a segwit address with the given hrp, returning
+// its witness version and program.
+func decodeSegWitAddress(hrp, addr string) (byte, []byte, error) {
+	decodedHrp, data, enc, err := bech32Decode(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if decodedHrp != hrp {
+		return 0, nil, fmt.Errorf("invalid address hrp '%s', expected '%s'", decodedHrp, hrp)
+	}
+
+	if len(data) < 1 {
+		return 0, nil, errors.New("missing witness version")
+	}
+
+	version := data[0]
+	if enc != witnessEncoding(version) {
+		return 0, nil, fmt.Errorf("invalid checksum encoding for witness version %d", version)
+	}
+
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := validateWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+
+	return version, program, nil
+}
+
+// segWitAddrFn returns the addrFn of a witness program. The program is
+// validated upfront so that the returned function never fails.
+func segWitAddrFn(version byte, program []byte) (func(*NetParams) string, error) {
+	if err := validateWitnessProgram(version, program); err != nil {
+		return nil, err
+	}
+
+	return func(net *NetParams) string {
+		addr, err := encodeSegWitAddress(net.Bech32HRP, version, program)
+		if err != nil {
+			panic(err)
+		}
+		return addr
+	}, nil
+}
+
+// witnessVersionOp returns the opcode pushing a witness version.
+func witnessVersionOp(version byte) byte {
+	if version == 0 {
+		return OP_0
+	}
+	return OP_1 + version - 1
+}
+
+// witnessProgramScript returns the scriptPubKey of a witness program.
+func witnessProgramScript(version byte, program []byte) []byte {
+	return NewBytes(
+		[]byte{witnessVersionOp(version), OP_PUSH_BYTES(len(program))},
+		program,
+	)
+}
+
+// DecodeAddress decodes a base58 (P2PKH or P2SH) or a segwit address for the
+// given network and returns its scriptPubKey.
+func DecodeAddress(addr string, net *NetParams) ([]byte, error) {
+	if net == nil {
+		return nil, ErrUnknownNetwork
+	}
+
+	version, program, err := decodeSegWitAddress(net.Bech32HRP, addr)
+	if err == nil {
+		return witnessProgramScript(version, program), nil
+	}
+
+	hash, prefix, err := base58.CheckDecode(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%s'", addr)
+	}
+
+	if len(hash) != 20 {
+		return nil, fmt.Errorf("invalid address hash length %d", len(hash))
+	}
+
+	switch prefix {
+	case net.PubKeyHashAddrID:
+		return NewBytes(
+			[]byte{OP_DUP, OP_HASH160, OP_PUSH_BYTES(20)},
+			hash,
+			[]byte{OP_EQUALVERIFY, OP_CHECKSIG},
+		), nil
+	case net.ScriptHashAddrID:
+		return NewBytes(
+			[]byte{OP_HASH160, OP_PUSH_BYTES(20)},
+			hash,
+			[]byte{OP_EQUAL},
+		), nil
+	}
+
+	return nil, fmt.Errorf("invalid address prefix 0x%02x", prefix)
+}
+
+// scriptAddrFn returns the addrFn of a scriptPubKey if it matches one of the
+// P2PKH, P2SH or witness program templates, otherwise its address is empty.
+func scriptAddrFn(script []byte) func(*NetParams) string {
+	switch {
+	case len(script) == 25 &&
+		script[0] == OP_DUP && script[1] == OP_HASH160 && script[2] == 20 &&
+		script[23] == OP_EQUALVERIFY && script[24] == OP_CHECKSIG:
+		hash := script[3:23]
+		return func(net *NetParams) string {
+			return base58.CheckEncode(hash, net.PubKeyHashAddrID)
+		}
+	case len(script) == 23 &&
+		script[0] == OP_HASH160 && script[1] == 20 && script[22] == OP_EQUAL:
+		hash := script[2:22]
+		return func(net *NetParams) string {
+			return base58.CheckEncode(hash, net.ScriptHashAddrID)
+		}
+	case len(script) >= 4 && len(script) <= 42 && int(script[1]) == len(script)-2 &&
+		(script[0] == OP_0 || (script[0] >= OP_1 && script[0] <= OP_1+15)):
+		version := byte(0)
+		if script[0] != OP_0 {
+			version = script[0] - OP_1 + 1
+		}
+		if addrFn, err := segWitAddrFn(version, script[2:]); err == nil {
+			return addrFn
+		}
+	}
+
+	return func(*NetParams) string { return "" }
+}
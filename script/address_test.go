@@ -0,0 +1,132 @@
+package script
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegWitAddresses(t *testing.T) {
+	// Test vectors from BIP350.
+	testCases := []struct {
+		hrp    string
+		addr   string
+		script string
+	}{
+		{"bc", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc", "BC1SW50QGDZ25J", "6002751e"},
+		{"bc", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+		{"tb", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"tb", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.addr, func(t *testing.T) {
+			version, program, err := decodeSegWitAddress(test.hrp, test.addr)
+			require.NoError(t, err)
+
+			script, _ := hex.DecodeString(test.script)
+			expectedVersion := script[0]
+			if expectedVersion != OP_0 {
+				expectedVersion -= OP_1 - 1
+			}
+			assert.Equal(t, expectedVersion, version)
+			assert.Equal(t, script[2:], program)
+
+			addr, err := encodeSegWitAddress(test.hrp, version, program)
+			require.NoError(t, err)
+			assert.Equal(t, strings.ToLower(test.addr), addr)
+		})
+	}
+}
+
+func TestInvalidSegWitAddresses(t *testing.T) {
+	// Test vectors from BIP350.
+	testCases := []struct {
+		addr   string
+		reason string
+	}{
+		{"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", "invalid hrp"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", "bech32 instead of bech32m"},
+		{"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", "bech32 instead of bech32m"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", "bech32m instead of bech32"},
+		{"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", "invalid character"},
+		{"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", "invalid witness version"},
+		{"bc1pw5dgrnzv", "invalid program length"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", "invalid program length"},
+		{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", "invalid program length for witness v0"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", "zero padding of more than 4 bits"},
+		{"bc1gmk9yu", "empty data section"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.reason, func(t *testing.T) {
+			_, _, err := decodeSegWitAddress("bc", test.addr)
+			assert.Error(t, err)
+		})
+	}
+
+	for _, test := range []string{
+		"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf",
+		"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j",
+	} {
+		_, _, err := decodeSegWitAddress("tb", test)
+		assert.Error(t, err, test)
+	}
+}
+
+func TestEncodeSegWitAddressValidation(t *testing.T) {
+	_, err := encodeSegWitAddress("bc", 17, make([]byte, 32))
+	assert.Error(t, err)
+
+	_, err = encodeSegWitAddress("bc", 0, make([]byte, 31))
+	assert.Error(t, err)
+
+	_, err = encodeSegWitAddress("bc", 1, make([]byte, 41))
+	assert.Error(t, err)
+}
+
+func TestDecodeAddress(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		net    *NetParams
+		script string
+	}{
+		{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", Mainnet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+		{"3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN", Mainnet, "a914bcfeb728b584253d5f3f70bcb780e9ef218a68f487"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Mainnet, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", Mainnet, "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{"mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r", Testnet, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+		{"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", Testnet, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", Signet, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080", Regtest, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.addr, func(t *testing.T) {
+			script, err := DecodeAddress(test.addr, test.net)
+			require.NoError(t, err)
+			assert.Equal(t, test.script, hex.EncodeToString(script))
+
+			assert.Equal(t, test.addr, scriptAddrFn(script)(test.net))
+		})
+	}
+
+	for _, addr := range []string{
+		"mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r",
+		"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx",
+		"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMX",
+		"not an address",
+	} {
+		_, err := DecodeAddress(addr, Mainnet)
+		assert.Error(t, err, addr)
+	}
+}
@@ -0,0 +1,230 @@
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scriptOp is a single opcode of a script along with its pushed data.
+type scriptOp struct {
+	op   byte
+	data []byte
+	// end is the offset of the script following the opcode.
+	end int
+}
+
+// isPush returns if the opcode pushes data, OP_0 included.
+func (o scriptOp) isPush() bool {
+	return o.op <= OP_PUSHDATA4
+}
+
+// parseOps splits a script into its opcodes. On error, the opcodes preceding
+// the invalid one are returned along with it.
+func parseOps(script []byte) ([]scriptOp, error) {
+	var ops []scriptOp
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+
+		var size int
+		switch {
+		case op < OP_PUSHDATA1:
+			size = int(op)
+		case op == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return ops, errors.New("script: truncated OP_PUSHDATA1")
+			}
+			size = int(script[i])
+			i++
+		case op == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return ops, errors.New("script: truncated OP_PUSHDATA2")
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case op == OP_PUSHDATA4:
+			if i+4 > len(script) {
+				return ops, errors.New("script: truncated OP_PUSHDATA4")
+			}
+			size = int(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		}
+
+		if size > len(script)-i {
+			return ops, fmt.Errorf("script: push of %d bytes exceeds the script size", size)
+		}
+
+		o := scriptOp{op: op, end: i + size}
+		if o.isPush() {
+			o.data = script[i : i+size]
+		}
+		ops = append(ops, o)
+		i += size
+	}
+
+	return ops, nil
+}
+
+// pushData returns the script pushing data onto the stack with the smallest
+// push opcode for its size.
+func pushData(data []byte) []byte {
+	n := len(data)
+	switch {
+	case n == 0:
+		return []byte{OP_0}
+	case n < OP_PUSHDATA1:
+		return NewBytes([]byte{OP_PUSH_BYTES(n)}, data)
+	case n <= 0xff:
+		return NewBytes([]byte{OP_PUSHDATA1, byte(n)}, data)
+	case n <= 0xffff:
+		return NewBytes([]byte{OP_PUSHDATA2, byte(n), byte(n >> 8)}, data)
+	}
+	return NewBytes([]byte{OP_PUSHDATA4, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, data)
+}
+
+// decodeScriptNum decodes a little endian sign-magnitude script number,
+// without requiring a minimal encoding.
+func decodeScriptNum(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << (8 * i)
+	}
+
+	// The sign is the most significant bit of the last byte.
+	if b[len(b)-1]&0x80 != 0 {
+		return -(n &^ (int64(0x80) << (8 * (len(b) - 1))))
+	}
+	return n
+}
+
+// Disassemble returns the Bitcoin Core ASM representation of a script, where
+// pushes of up to 4 bytes are printed as numbers and longer ones as hex.
+//
+// As in Bitcoin Core, long pushes whose hex reads as a number are assembled
+// back as numbers, see DisassembleRaw for a form Assemble round-trips.
+func Disassemble(script []byte) (string, error) {
+	return disassemble(script, false)
+}
+
+// DisassembleRaw is like Disassemble but prints the long pushes whose hex
+// reads as a number as `0x` prefixed raw bytes, so that Assemble returns the
+// original script.
+func DisassembleRaw(script []byte) (string, error) {
+	return disassemble(script, true)
+}
+
+func disassemble(script []byte, raw bool) (string, error) {
+	ops, err := parseOps(script)
+	if err != nil {
+		return "", err
+	}
+
+	asm := make([]string, len(ops))
+	start := 0
+	for i, o := range ops {
+		switch data := hex.EncodeToString(o.data); {
+		case !o.isPush():
+			asm[i] = OpcodeName(o.op)
+		case len(o.data) <= 4:
+			asm[i] = strconv.FormatInt(decodeScriptNum(o.data), 10)
+		case raw && isScriptNumber(data):
+			// Data whose hex looks like a number would be assembled as such,
+			// so the push is printed as raw bytes instead.
+			asm[i] = "0x" + hex.EncodeToString(script[start:o.end])
+		default:
+			asm[i] = data
+		}
+		start = o.end
+	}
+
+	return strings.Join(asm, " "), nil
+}
+
+// opcodesByName maps opcode names, with and without the OP_ prefix, to
+// their values.
+var opcodesByName = func() map[string]byte {
+	names := make(map[string]byte)
+	add := func(name string, op byte) {
+		names[name] = op
+		names[strings.TrimPrefix(name, "OP_")] = op
+	}
+
+	for op, name := range opcodeNames {
+		// Numbers are assembled as such and a lone push opcode would consume
+		// the following ones.
+		if op == OP_0 || op == OP_1NEGATE || (op >= OP_PUSHDATA1 && op <= OP_PUSHDATA4) {
+			continue
+		}
+		add(name, op)
+	}
+	for n := 1; n <= 16; n++ {
+		add("OP_"+strconv.Itoa(n), OP_N(n))
+	}
+	add("OP_0", OP_0)
+	add("OP_FALSE", OP_FALSE)
+	add("OP_TRUE", OP_TRUE)
+	add("OP_1NEGATE", OP_1NEGATE)
+	add("OP_NOP2", OP_NOP2)
+	add("OP_NOP3", OP_NOP3)
+	return names
+}()
+
+// Assemble returns the script of an ASM string as printed by DisassembleRaw.
+// Decimal numbers are pushed as script numbers, hex strings as data and
+// `0x` prefixed hex strings are inserted as raw bytes. Opcodes can be named
+// with or without the OP_ prefix.
+//
+// As in Bitcoin Core, ASM is ambiguous for non minimal pushes, which
+// disassemble into minimal ones.
+func Assemble(asm string) ([]byte, error) {
+	var bufs [][]byte
+	for _, token := range strings.Fields(asm) {
+		if op, ok := opcodesByName[token]; ok {
+			bufs = append(bufs, []byte{op})
+			continue
+		}
+
+		if isScriptNumber(token) {
+			n, _ := strconv.ParseInt(token, 10, 64)
+			bufs = append(bufs, pushNumber(n))
+			continue
+		}
+
+		if strings.HasPrefix(token, "0x") {
+			raw, err := hex.DecodeString(token[2:])
+			if err != nil || len(raw) == 0 {
+				return nil, fmt.Errorf("script: invalid raw bytes '%s'", token)
+			}
+			bufs = append(bufs, raw)
+			continue
+		}
+
+		data, err := hex.DecodeString(token)
+		if err != nil || len(data) == 0 {
+			return nil, fmt.Errorf("script: invalid token '%s'", token)
+		}
+		bufs = append(bufs, pushData(data))
+	}
+
+	return NewBytes(bufs...), nil
+}
+
+// isScriptNumber returns if an ASM token is a decimal number which fits a 4
+// byte script number, which is the largest Disassemble prints as a number.
+func isScriptNumber(token string) bool {
+	digits := strings.TrimPrefix(token, "-")
+	if digits == "" || (len(digits) > 1 && digits[0] == '0') {
+		return false
+	}
+
+	n, err := strconv.ParseInt(token, 10, 64)
+	return err == nil && n >= -(1<<31-1) && n <= 1<<31-1
+}
@@ -0,0 +1,148 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisassemble(t *testing.T) {
+	testCases := []struct {
+		script string
+		asm    string
+	}{
+		{
+			script: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+			asm:    "OP_DUP OP_HASH160 751e76e8199196d454941c45d1b3a323f1433bd6 OP_EQUALVERIFY OP_CHECKSIG",
+		},
+		{
+			script: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+			asm:    "0 751e76e8199196d454941c45d1b3a323f1433bd6",
+		},
+		{
+			script: "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			asm:    "1 79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		},
+		{
+			script: "02e803b2",
+			asm:    "1000 OP_CHECKSEQUENCEVERIFY",
+		},
+		{
+			script: "4f60029000",
+			asm:    "-1 16 144",
+		},
+		{
+			script: "0181",
+			asm:    "-1",
+		},
+		{
+			script: "6a4c050102030405",
+			asm:    "OP_RETURN 0102030405",
+		},
+		{
+			script: "bbba",
+			asm:    "OP_UNKNOWN OP_CHECKSIGADD",
+		},
+		{
+			// As in Bitcoin Core, data reading as a number is printed as hex.
+			script: "051122334455",
+			asm:    "1122334455",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.asm, func(t *testing.T) {
+			asm, err := Disassemble(mustDecodeHex(test.script))
+			require.NoError(t, err)
+			assert.Equal(t, test.asm, asm)
+		})
+	}
+}
+
+func TestDisassembleTruncated(t *testing.T) {
+	for _, script := range []string{"4c", "4d01", "4e010000", "0201", "4c0201"} {
+		_, err := Disassemble(mustDecodeHex(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	testCases := []struct {
+		asm    string
+		script string
+	}{
+		{
+			asm:    "OP_DUP OP_HASH160 751e76e8199196d454941c45d1b3a323f1433bd6 OP_EQUALVERIFY OP_CHECKSIG",
+			script: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+		},
+		{
+			asm:    "DUP HASH160 751e76e8199196d454941c45d1b3a323f1433bd6 EQUALVERIFY CHECKSIG",
+			script: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+		},
+		{
+			asm:    "0 -1 1 16 17 1000 -1000 2147483647",
+			script: "004f5160011102e80302e88304ffffff7f",
+		},
+		{
+			asm:    "OP_TRUE OP_FALSE OP_NOP2 OP_CHECKSIGADD",
+			script: "5100b1ba",
+		},
+		{
+			asm:    "0x4c01 ff",
+			script: "4c0101ff",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.asm, func(t *testing.T) {
+			script, err := Assemble(test.asm)
+			require.NoError(t, err)
+			assert.Equal(t, test.script, hex.EncodeToString(script))
+		})
+	}
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	for _, desc := range []string{
+		"pkh(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+		"multi(1,0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798,02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)",
+		"wsh(or_d(pk(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798),and_v(v:pk(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5),older(1000))))",
+		"tr(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+	} {
+		script, err := Parse(desc)
+		require.NoError(t, err)
+
+		asm, err := DisassembleRaw(script.Bytes())
+		require.NoError(t, err)
+
+		b, err := Assemble(asm)
+		require.NoError(t, err)
+		assert.Equal(t, script.Bytes(), b, asm)
+	}
+}
+
+func TestAssembleRoundTripNumericHex(t *testing.T) {
+	for _, script := range []string{
+		"051122334455",
+		"4c051122334455",
+		"6a051234567890",
+		"0520000000007551",
+	} {
+		asm, err := DisassembleRaw(mustDecodeHex(script))
+		require.NoError(t, err)
+		assert.Contains(t, asm, "0x")
+
+		b, err := Assemble(asm)
+		require.NoError(t, err)
+		assert.Equal(t, script, hex.EncodeToString(b), asm)
+	}
+}
+
+func TestInvalidAssemble(t *testing.T) {
+	for _, asm := range []string{"OP_FOO", "abc", "0x", "0xzz", "01 OP_PUSHDATA1", "007"} {
+		_, err := Assemble(asm)
+		assert.Error(t, err, asm)
+	}
+}
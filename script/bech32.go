@@ -0,0 +1,112 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bech32Charset is the set of characters used in the data section of bech32
+// strings, indexed by their 5 bit value.
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Encoding selects the checksum constant used by the encoder, as
+// described in BIP173 (bech32) and BIP350 (bech32m).
+type bech32Encoding uint32
+
+const (
+	encBech32  bech32Encoding = 1
+	encBech32m bech32Encoding = 0x2bc830a3
+)
+
+var bech32Gen = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= bech32Gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+func bech32Checksum(hrp string, data []byte, enc bech32Encoding) []byte {
+	values := NewBytes(bech32HrpExpand(hrp), data, make([]byte, 6))
+	polymod := bech32Polymod(values) ^ uint32(enc)
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// bech32Encode encodes 5 bit groups of data with the given hrp and checksum
+// encoding.
+func bech32Encode(hrp string, data []byte, enc bech32Encoding) string {
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, b := range NewBytes(data, bech32Checksum(hrp, data, enc)) {
+		sb.WriteByte(bech32Charset[b])
+	}
+	return sb.String()
+}
+
+// bech32Decode decodes a bech32 or bech32m string returning its hrp, the 5 bit
+// groups of data (without the checksum) and the detected checksum encoding.
+func bech32Decode(s string) (string, []byte, bech32Encoding, error) {
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("bech32: string too long (%d)", len(s))
+	}
+
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("bech32: mixed case string")
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, 0, errors.New("bech32: invalid separator position")
+	}
+
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("bech32: invalid hrp character %q", hrp[i])
+		}
+	}
+
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, 0, fmt.Errorf("bech32: invalid character %q", s[i])
+		}
+		data = append(data, byte(v))
+	}
+
+	enc := bech32Encoding(bech32Polymod(NewBytes(bech32HrpExpand(hrp), data)))
+	if enc != encBech32 && enc != encBech32m {
+		return "", nil, 0, errors.New("bech32: invalid checksum")
+	}
+
+	return hrp, data[:len(data)-6], enc, nil
+}
@@ -11,3 +11,64 @@ func NewBytes(bufs ...[]byte) []byte {
 	}
 	return buf.Bytes()
 }
+
+// compactSize returns the Bitcoin variable length integer encoding of n.
+func compactSize(n int) []byte {
+	switch {
+	case n < 0xfd:
+		return []byte{byte(n)}
+	case n <= 0xffff:
+		return []byte{0xfd, byte(n), byte(n >> 8)}
+	case n <= 0xffffffff:
+		return []byte{0xfe, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}
+	}
+	return []byte{0xff,
+		byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24),
+		byte(n >> 32), byte(n >> 40), byte(n >> 48), byte(n >> 56),
+	}
+}
+
+// scriptNum returns the minimal little endian sign-magnitude encoding of n
+// used by script numbers.
+func scriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	neg := n < 0
+	abs := uint64(n)
+	if neg {
+		abs = uint64(-n)
+	}
+
+	var num []byte
+	for ; abs > 0; abs >>= 8 {
+		num = append(num, byte(abs))
+	}
+
+	// The most significant bit is the sign, add an extra byte if it's
+	// already in use.
+	if num[len(num)-1]&0x80 != 0 {
+		num = append(num, 0x00)
+	}
+	if neg {
+		num[len(num)-1] |= 0x80
+	}
+	return num
+}
+
+// pushNumber returns the minimal script to push n onto the stack, using the
+// OP_0, OP_1NEGATE and OP_1 to OP_16 opcodes when possible.
+func pushNumber(n int64) []byte {
+	switch {
+	case n == 0:
+		return []byte{OP_0}
+	case n == -1:
+		return []byte{OP_1NEGATE}
+	case n >= 1 && n <= 16:
+		return []byte{OP_N(int(n))}
+	}
+
+	num := scriptNum(n)
+	return NewBytes([]byte{OP_PUSH_BYTES(len(num))}, num)
+}
@@ -0,0 +1,120 @@
+package script
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Descriptor checksums as described in BIP380.
+
+// checksumInputCharset is the set of characters allowed in a descriptor, the
+// position of each character is used to compute the checksum.
+const checksumInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+const checksumLength = 8
+
+var checksumGen = []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+func checksumPolymod(symbols []uint64) uint64 {
+	chk := uint64(1)
+	for _, v := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= checksumGen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// checksumExpand converts a descriptor into the symbols used by the checksum.
+func checksumExpand(desc string) ([]uint64, error) {
+	var symbols, groups []uint64
+	for i, r := range desc {
+		v := strings.IndexRune(checksumInputCharset, r)
+		if v < 0 {
+			return nil, fmt.Errorf("invalid descriptor character %q at position %d", r, i)
+		}
+
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+
+	return symbols, nil
+}
+
+// DescriptorChecksum computes the checksum of a descriptor, which must not
+// contain a `#checksum` suffix.
+func DescriptorChecksum(desc string) (string, error) {
+	symbols, err := checksumExpand(desc)
+	if err != nil {
+		return "", err
+	}
+
+	symbols = append(symbols, make([]uint64, checksumLength)...)
+	polymod := checksumPolymod(symbols) ^ 1
+
+	var sb strings.Builder
+	for i := 0; i < checksumLength; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(checksumLength-1-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// splitChecksum splits a descriptor and its checksum (if any), verifying it
+// when present.
+func splitChecksum(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.LastIndexByte(s, '#')
+	if i < 0 {
+		return s, "", nil
+	}
+
+	desc, checksum := s[:i], s[i+1:]
+	if len(checksum) != checksumLength {
+		return "", "", fmt.Errorf("invalid descriptor checksum length %d, expected %d", len(checksum), checksumLength)
+	}
+
+	expected, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", "", err
+	}
+
+	if checksum != expected {
+		return "", "", fmt.Errorf("invalid descriptor checksum '%s', expected '%s'", checksum, expected)
+	}
+
+	return desc, checksum, nil
+}
+
+// AddChecksum returns the descriptor with its `#checksum` suffix. If the
+// descriptor already has a checksum it gets verified.
+func AddChecksum(s string) (string, error) {
+	desc, _, err := splitChecksum(s)
+	if err != nil {
+		return "", err
+	}
+
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+
+	return desc + "#" + checksum, nil
+}
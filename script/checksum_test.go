@@ -0,0 +1,59 @@
+package script_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/qustavo/go-wallet/script"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	testCases := []string{
+		"pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)#ml40v0wf",
+		"sh(wpkh(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556))#qkrrc7je",
+		"wpkh(02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9)#8zl0zxma",
+		"raw(deadbeef)#89f8spxm",
+	}
+
+	for _, test := range testCases {
+		t.Run(test, func(t *testing.T) {
+			desc := test[:len(test)-9]
+
+			checksum, err := DescriptorChecksum(desc)
+			require.NoError(t, err)
+			assert.Equal(t, test[len(test)-8:], checksum)
+
+			withChecksum, err := AddChecksum(desc)
+			require.NoError(t, err)
+			assert.Equal(t, test, withChecksum)
+
+			withChecksum, err = AddChecksum(test)
+			require.NoError(t, err)
+			assert.Equal(t, test, withChecksum)
+
+			_, err = Parse(test)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestInvalidDescriptorChecksum(t *testing.T) {
+	for _, desc := range []string{
+		"raw(deadbeef)#89f8spxn",
+		"raw(deadbeef)#89f8spx",
+		"raw(deadbeef)#",
+		"raw(deadbeee)#89f8spxm",
+		"raw(deadbeef)##89f8spxm",
+	} {
+		_, err := Parse(desc)
+		assert.Error(t, err, desc)
+
+		_, err = AddChecksum(desc)
+		assert.Error(t, err, desc)
+	}
+
+	_, err := DescriptorChecksum("raw(deadbeef)\n")
+	assert.Error(t, err)
+}
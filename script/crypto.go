@@ -2,9 +2,12 @@ package script
 
 import (
 	"crypto/sha256"
+	"errors"
 	"hash"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
 
-	"github.com/btcsuite/btcutil/bech32"
 	"golang.org/x/crypto/ripemd160" // nolint:staticcheck // SA1019 ripem160 is deprecated but it is used by Bitcoin
 )
 
@@ -23,24 +26,64 @@ func Hash160(buf []byte) []byte {
 	)
 }
 
-// Taken from btcutil
-func encodeSegWitAddress(hrp string, witnessVersion byte, witnessProgram []byte) (string, error) {
-	// Group the address bytes into 5 bit groups, as this is what is used to
-	// encode each character in the address string.
-	converted, err := bech32.ConvertBits(witnessProgram, 8, 5, true)
+// TaggedHash computes the BIP340 tagged hash
+// SHA256(SHA256(tag) || SHA256(tag) || msg).
+func TaggedHash(tag string, msgs ...[]byte) []byte {
+	tagHash := Sha256([]byte(tag))
+	hasher := sha256.New()
+	hasher.Write(tagHash)
+	hasher.Write(tagHash)
+	for _, msg := range msgs {
+		hasher.Write(msg)
+	}
+	return hasher.Sum(nil)
+}
+
+// liftX returns the point with the given x coordinate and an even y
+// coordinate, as defined by BIP340.
+func liftX(x []byte) (*btcec.PublicKey, error) {
+	if len(x) != 32 {
+		return nil, errors.New("x-only key must be 32 bytes")
+	}
+	return parsePubKey(NewBytes([]byte{0x02}, x))
+}
+
+// parsePubKey parses a serialized secp256k1 point. btcec doesn't verify that
+// the x coordinate of compressed keys is a field element, so it's done here.
+func parsePubKey(key []byte) (*btcec.PublicKey, error) {
+	pub, err := btcec.ParsePubKey(key, btcec.S256())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Concatenate the witness version and program, and encode the resulting
-	// bytes using bech32 encoding.
-	combined := make([]byte, len(converted)+1)
-	combined[0] = witnessVersion
-	copy(combined[1:], converted)
-	bech, err := bech32.Encode(hrp, combined)
+	if pub.X.Cmp(btcec.S256().P) >= 0 {
+		return nil, errors.New("pubkey X parameter is >= to P")
+	}
+	return pub, nil
+}
+
+// taprootTweak tweaks the x-only internal key with the given merkle root
+// (which may be nil for key-path only outputs) as described in BIP341.
+// It returns the x-only output key and the parity of its y coordinate.
+func taprootTweak(internalKey, merkleRoot []byte) ([]byte, byte, error) {
+	p, err := liftX(internalKey)
 	if err != nil {
-		return "", err
+		return nil, 0, err
+	}
+
+	curve := btcec.S256()
+	t := TaggedHash("TapTweak", internalKey, merkleRoot)
+	if new(big.Int).SetBytes(t).Cmp(curve.N) >= 0 {
+		return nil, 0, errors.New("taproot tweak exceeds curve order")
+	}
+
+	tx, ty := curve.ScalarBaseMult(t)
+	qx, qy := curve.Add(p.X, p.Y, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, 0, errors.New("taproot output key is infinity")
 	}
 
-	return bech, nil
+	outputKey := make([]byte, 32)
+	qx.FillBytes(outputKey)
+	return outputKey, byte(qy.Bit(0)), nil
 }
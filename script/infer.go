@@ -0,0 +1,192 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// inferContext is where an inferred script is nested, which restricts the
+// scripts and keys it can contain.
+type inferContext int
+
+const (
+	inferTop inferContext = iota
+	inferSh
+	inferWsh
+)
+
+// InferDescriptor returns the descriptor of a scriptPubKey. known are the
+// redeem scripts, witness scripts and public keys the scriptPubKey may commit
+// to, which are needed to infer sh(), wsh(), pkh(), wpkh() and tr()
+// descriptors. Scripts which don't match any template, or whose committed
+// data is unknown, fall back to addr() on the given network or to raw(), which
+// is also used when the network is nil.
+func InferDescriptor(scriptPubKey []byte, net *NetParams, known ...[]byte) ScriptExpr {
+	// Inferred descriptors must evaluate back to the same script, which also
+	// rejects the ones exceeding the script limits.
+	if expr := inferScript(scriptPubKey, inferTop, known); expr != nil {
+		if script, err := expr.Eval(); err == nil && bytes.Equal(script.Bytes(), scriptPubKey) {
+			return expr
+		}
+	}
+
+	if net != nil {
+		if addr := scriptAddrFn(scriptPubKey)(net); addr != "" {
+			return Addr(addr)
+		}
+	}
+	return Raw(hex.EncodeToString(scriptPubKey))
+}
+
+// inferScript returns the descriptor of a script in the given context, or nil
+// if it can't be inferred.
+func inferScript(script []byte, ctx inferContext, known [][]byte) ScriptExpr {
+	if key, ok := matchPk(script); ok {
+		if inferKey(key, ctx) {
+			return Pk(hex.EncodeToString(key))
+		}
+		return nil
+	}
+
+	if m, keys, ok := matchMulti(script); ok {
+		hexKeys := make([]string, len(keys))
+		for i, key := range keys {
+			if !inferKey(key, ctx) {
+				return nil
+			}
+			hexKeys[i] = hex.EncodeToString(key)
+		}
+		return Multi(m, hexKeys...)
+	}
+
+	if hash, ok := matchPkh(script); ok {
+		if key := findKnown(known, hash, Hash160); key != nil && inferKey(key, ctx) {
+			return Pkh(hex.EncodeToString(key))
+		}
+		return nil
+	}
+
+	if ctx == inferTop && isP2SH(script) {
+		if redeemScript := findKnown(known, script[2:22], Hash160); redeemScript != nil {
+			if expr := inferScript(redeemScript, inferSh, known); expr != nil {
+				return Sh(expr)
+			}
+		}
+		return nil
+	}
+
+	version, program, ok := witnessProgram(script)
+	if !ok || ctx == inferWsh {
+		return nil
+	}
+
+	switch {
+	case version == 0 && len(program) == 20:
+		if key := findKnown(known, program, Hash160); key != nil && inferKey(key, inferWsh) {
+			return Wpkh(hex.EncodeToString(key))
+		}
+	case version == 0 && len(program) == 32:
+		if witnessScript := findKnown(known, program, Sha256); witnessScript != nil {
+			if expr := inferScript(witnessScript, inferWsh, known); expr != nil {
+				return Wsh(expr)
+			}
+		}
+	case version == 1 && len(program) == 32 && ctx == inferTop:
+		// Only key path outputs can be inferred, script trees aren't known.
+		for _, key := range known {
+			internalKey, err := xOnly(key)
+			if err != nil {
+				continue
+			}
+			if outputKey, _, err := taprootTweak(internalKey, nil); err == nil && bytes.Equal(outputKey, program) {
+				return Tr(hex.EncodeToString(internalKey), nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// inferKey returns if a public key is valid in the context, segwit requires
+// compressed keys.
+func inferKey(key []byte, ctx inferContext) bool {
+	if _, err := parsePubKey(key); err != nil {
+		return false
+	}
+	return ctx != inferWsh || len(key) == 33
+}
+
+// findKnown returns the known data with the given hash.
+func findKnown(known [][]byte, hash []byte, hashFn func([]byte) []byte) []byte {
+	for _, data := range known {
+		if bytes.Equal(hashFn(data), hash) {
+			return data
+		}
+	}
+	return nil
+}
+
+// matchPk matches `<key> OP_CHECKSIG`.
+func matchPk(script []byte) ([]byte, bool) {
+	ops, err := parseOps(script)
+	if err != nil || len(ops) != 2 || ops[1].op != OP_CHECKSIG {
+		return nil, false
+	}
+	if !isKeyPush(ops[0]) {
+		return nil, false
+	}
+	return ops[0].data, true
+}
+
+// isKeyPush returns if the opcode pushes a compressed or uncompressed key.
+func isKeyPush(o scriptOp) bool {
+	n := len(o.data)
+	return int(o.op) == n && (n == 33 || n == 65)
+}
+
+// matchPkh matches `OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG`.
+func matchPkh(script []byte) ([]byte, bool) {
+	if len(script) == 25 && script[0] == OP_DUP && script[1] == OP_HASH160 &&
+		script[2] == 20 && script[23] == OP_EQUALVERIFY && script[24] == OP_CHECKSIG {
+		return script[3:23], true
+	}
+	return nil, false
+}
+
+// matchMulti matches `<m> <key>... <n> OP_CHECKMULTISIG`.
+func matchMulti(script []byte) (int, [][]byte, bool) {
+	ops, err := parseOps(script)
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].op != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+
+	m, okM := opNumber(ops[0])
+	n, okN := opNumber(ops[len(ops)-2])
+	keyOps := ops[1 : len(ops)-2]
+	if !okM || !okN || n != len(keyOps) || m < 1 || m > n {
+		return 0, nil, false
+	}
+
+	keys := make([][]byte, n)
+	for i, o := range keyOps {
+		if !isKeyPush(o) {
+			return 0, nil, false
+		}
+		keys[i] = o.data
+	}
+	return m, keys, true
+}
+
+// opNumber returns the number pushed by an opcode, which must be minimally
+// encoded as done by pushNumber.
+func opNumber(o scriptOp) (int, bool) {
+	if o.op >= OP_1 && o.op <= OP_16 {
+		return int(o.op-OP_1) + 1, true
+	}
+	if !o.isPush() || o.op == OP_0 || !isMinimalPush(o) {
+		return 0, false
+	}
+
+	n, err := parseScriptNum(o.data, true, maxScriptNumSize)
+	return int(n), err == nil
+}
@@ -0,0 +1,87 @@
+package script
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferDescriptor(t *testing.T) {
+	const (
+		keyA   = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB   = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+		keyU   = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+		xOnlyA = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	)
+	r := strings.NewReplacer("A", keyA, "B", keyB, "U", keyU, "X", xOnlyA)
+
+	testCases := []struct {
+		desc string
+		// known are the descriptors whose scripts are known, or keys.
+		known    []string
+		expected string
+	}{
+		{desc: "pk(A)", expected: "pk(A)"},
+		{desc: "pk(U)", expected: "pk(U)"},
+		{desc: "pkh(A)", known: []string{"A"}, expected: "pkh(A)"},
+		{desc: "pkh(A)", expected: "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)"},
+		{desc: "wpkh(A)", known: []string{"A"}, expected: "wpkh(A)"},
+		{desc: "wpkh(A)", expected: "addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)"},
+		{desc: "multi(1,A,B)", expected: "multi(1,A,B)"},
+		{desc: "sortedmulti(1,B,A)", expected: "multi(1,A,B)"},
+		{desc: "sh(multi(2,A,U))", known: []string{"multi(2,A,U)"}, expected: "sh(multi(2,A,U))"},
+		{desc: "sh(wpkh(A))", known: []string{"wpkh(A)", "A"}, expected: "sh(wpkh(A))"},
+		{desc: "sh(wsh(pkh(A)))", known: []string{"wsh(pkh(A))", "pkh(A)", "A"}, expected: "sh(wsh(pkh(A)))"},
+		{desc: "wsh(multi(1,A,B))", known: []string{"multi(1,A,B)"}, expected: "wsh(multi(1,A,B))"},
+		{desc: "tr(X)", known: []string{"A"}, expected: "tr(X)"},
+		{desc: "raw(6a00)", expected: "raw(6a00)"},
+		{desc: "raw(51)", expected: "raw(51)"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			script, err := Parse(r.Replace(test.desc))
+			require.NoError(t, err)
+
+			var known [][]byte
+			for _, k := range test.known {
+				if b, err := hex.DecodeString(r.Replace(k)); err == nil {
+					known = append(known, b)
+					continue
+				}
+
+				s, err := Parse(r.Replace(k))
+				require.NoError(t, err)
+				known = append(known, s.Bytes())
+			}
+
+			expected := test.expected
+			if !strings.HasPrefix(expected, "addr(") {
+				expected = r.Replace(expected)
+			}
+
+			expr := InferDescriptor(script.Bytes(), Mainnet, known...)
+			assert.Equal(t, expected, descriptor(expr))
+
+			inferred, err := expr.Eval()
+			require.NoError(t, err)
+			assert.Equal(t, script.Bytes(), inferred.Bytes())
+		})
+	}
+}
+
+func TestInferDescriptorContext(t *testing.T) {
+	const keyU = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+
+	// Uncompressed keys aren't allowed in segwit, so the witness script can't
+	// be inferred.
+	witnessScript := mustDecodeHex("41" + keyU + "ac")
+	program := Sha256(witnessScript)
+	scriptPubKey := NewBytes([]byte{OP_0, OP_PUSH_BYTES(32)}, program)
+
+	expr := InferDescriptor(scriptPubKey, Testnet, witnessScript)
+	assert.True(t, strings.HasPrefix(expr.String(), "addr(tb1q"), expr.String())
+}
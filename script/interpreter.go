@@ -0,0 +1,1306 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint:gosec // OP_SHA1 is part of the script language
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+
+	"golang.org/x/crypto/ripemd160" // nolint:staticcheck // SA1019 ripem160 is deprecated but it is used by Bitcoin
+)
+
+// The interpreter follows the consensus and standardness rules of Bitcoin
+// Core. Transactions are abstracted by a SignatureChecker, so scripts can be
+// verified without building the spending transaction.
+
+// ScriptFlags are the script verification flags, which enable soft forks and
+// standardness rules.
+type ScriptFlags uint32
+
+const (
+	// VerifyP2SH evaluates P2SH redeem scripts (BIP16).
+	VerifyP2SH ScriptFlags = 1 << iota
+	// VerifyStrictEncoding requires valid signature hash types and public key
+	// encodings.
+	VerifyStrictEncoding
+	// VerifyDERSignatures requires strict DER signatures (BIP66).
+	VerifyDERSignatures
+	// VerifyLowS requires signatures with a low S value.
+	VerifyLowS
+	// VerifyNullDummy requires the extra OP_CHECKMULTISIG argument to be
+	// empty (BIP147).
+	VerifyNullDummy
+	// VerifySigPushOnly requires scriptSigs to only push data.
+	VerifySigPushOnly
+	// VerifyMinimalData requires minimal pushes and numbers.
+	VerifyMinimalData
+	// VerifyDiscourageUpgradableNops fails on the reserved OP_NOPs.
+	VerifyDiscourageUpgradableNops
+	// VerifyCleanStack requires a single element left on the stack.
+	VerifyCleanStack
+	// VerifyCheckLockTimeVerify enables OP_CHECKLOCKTIMEVERIFY (BIP65).
+	VerifyCheckLockTimeVerify
+	// VerifyCheckSequenceVerify enables OP_CHECKSEQUENCEVERIFY (BIP112).
+	VerifyCheckSequenceVerify
+	// VerifyWitness evaluates segwit programs (BIP141).
+	VerifyWitness
+	// VerifyDiscourageUpgradableWitnessProgram fails on unknown witness
+	// versions.
+	VerifyDiscourageUpgradableWitnessProgram
+	// VerifyMinimalIf requires OP_IF arguments to be empty or 0x01 in segwit
+	// v0, it's always enforced in tapscript.
+	VerifyMinimalIf
+	// VerifyNullFail requires failing signatures to be empty.
+	VerifyNullFail
+	// VerifyWitnessPubKeyType requires compressed keys in segwit v0.
+	VerifyWitnessPubKeyType
+	// VerifyTaproot evaluates Taproot outputs (BIP341 and BIP342).
+	VerifyTaproot
+	// VerifyDiscourageUpgradableTaprootVersion fails on unknown leaf
+	// versions.
+	VerifyDiscourageUpgradableTaprootVersion
+	// VerifyDiscourageOpSuccess fails on OP_SUCCESSx opcodes.
+	VerifyDiscourageOpSuccess
+	// VerifyDiscourageUpgradablePubKeyType fails on unknown tapscript public
+	// key types.
+	VerifyDiscourageUpgradablePubKeyType
+)
+
+// ConsensusVerifyFlags are the rules enforced by consensus.
+const ConsensusVerifyFlags = VerifyP2SH | VerifyDERSignatures | VerifyNullDummy |
+	VerifyCheckLockTimeVerify | VerifyCheckSequenceVerify | VerifyWitness | VerifyTaproot
+
+// StandardVerifyFlags are the rules enforced by Bitcoin Core's mempool, a
+// spend failing them isn't relayed.
+const StandardVerifyFlags = ConsensusVerifyFlags | VerifyStrictEncoding | VerifyLowS |
+	VerifyMinimalData | VerifyDiscourageUpgradableNops | VerifyCleanStack |
+	VerifyDiscourageUpgradableWitnessProgram | VerifyMinimalIf | VerifyNullFail |
+	VerifyWitnessPubKeyType | VerifyDiscourageUpgradableTaprootVersion |
+	VerifyDiscourageOpSuccess | VerifyDiscourageUpgradablePubKeyType
+
+// SigVersion is the set of rules a script is executed with.
+type SigVersion int
+
+const (
+	SigVersionBase SigVersion = iota
+	SigVersionWitnessV0
+	SigVersionTaproot
+	SigVersionTapscript
+)
+
+// SignatureChecker verifies the parts of a script which depend on the
+// spending transaction.
+type SignatureChecker interface {
+	// CheckECDSASignature checks a DER signature, followed by its sighash
+	// type, of the input spending scriptCode.
+	CheckECDSASignature(sig, pubKey, scriptCode []byte, version SigVersion) bool
+	// CheckSchnorrSignature checks a BIP340 signature, optionally followed by
+	// its sighash type, against an x-only key. leafHash is nil for Taproot
+	// key path spends and codeSepPos is the position of the last executed
+	// OP_CODESEPARATOR, or 0xffffffff.
+	CheckSchnorrSignature(sig, pubKey, leafHash []byte, codeSepPos uint32) bool
+	// CheckLockTime checks an OP_CHECKLOCKTIMEVERIFY argument against the
+	// transaction lock time.
+	CheckLockTime(lockTime int64) bool
+	// CheckSequence checks an OP_CHECKSEQUENCEVERIFY argument against the
+	// input sequence.
+	CheckSequence(sequence int64) bool
+}
+
+// ErrEvalFalse is returned when a script succeeds but leaves a false value
+// on the stack.
+var ErrEvalFalse = errors.New("script: evaluated to false")
+
+const (
+	maxScriptSize        = 10000
+	maxScriptElementSize = 520
+	maxOpsPerScript      = 201
+	maxPubKeysPerMulti   = 20
+	maxStackSize         = 1000
+	maxScriptNumSize     = 4
+	// validationWeightPerSig is the tapscript signature budget consumed by
+	// each signature (BIP342).
+	validationWeightPerSig = 50
+	annexTag               = 0x50
+)
+
+var errInvalidStackOp = errors.New("script: invalid stack operation")
+
+// Verify verifies that the scriptSig and witness spend the script.
+func (s *Script) Verify(scriptSig []byte, witness [][]byte, flags ScriptFlags, checker SignatureChecker) error {
+	return VerifyScript(scriptSig, s.Bytes(), witness, flags, checker)
+}
+
+// VerifyScript verifies that the scriptSig and witness spend scriptPubKey.
+func VerifyScript(scriptSig, scriptPubKey []byte, witness [][]byte, flags ScriptFlags, checker SignatureChecker) error {
+	if flags&VerifySigPushOnly != 0 && !isPushOnly(scriptSig) {
+		return errors.New("script: scriptSig must only push data")
+	}
+
+	stack, err := evalScript(nil, scriptSig, flags, checker, SigVersionBase)
+	if err != nil {
+		return err
+	}
+	p2shStack := append([][]byte(nil), stack...)
+
+	if stack, err = evalScript(stack, scriptPubKey, flags, checker, SigVersionBase); err != nil {
+		return err
+	}
+	if len(stack) == 0 || !castToBool(stack[len(stack)-1]) {
+		return ErrEvalFalse
+	}
+
+	hadWitness := false
+	if flags&VerifyWitness != 0 {
+		if version, program, ok := witnessProgram(scriptPubKey); ok {
+			hadWitness = true
+			if len(scriptSig) != 0 {
+				return errors.New("script: scriptSig must be empty when spending a witness program")
+			}
+			if err := verifyWitnessProgram(witness, version, program, flags, checker, false); err != nil {
+				return err
+			}
+			stack = stack[:1]
+		}
+	}
+
+	if flags&VerifyP2SH != 0 && isP2SH(scriptPubKey) {
+		if !isPushOnly(scriptSig) {
+			return errors.New("script: P2SH scriptSig must only push data")
+		}
+
+		// scriptPubKey succeeded, so the stack contains the redeem script.
+		stack = p2shStack
+		redeemScript := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if stack, err = evalScript(stack, redeemScript, flags, checker, SigVersionBase); err != nil {
+			return err
+		}
+		if len(stack) == 0 || !castToBool(stack[len(stack)-1]) {
+			return ErrEvalFalse
+		}
+
+		if flags&VerifyWitness != 0 {
+			if version, program, ok := witnessProgram(redeemScript); ok {
+				hadWitness = true
+				if !bytes.Equal(scriptSig, pushData(redeemScript)) {
+					return errors.New("script: P2SH witness scriptSig must be a single push of the redeem script")
+				}
+				if err := verifyWitnessProgram(witness, version, program, flags, checker, true); err != nil {
+					return err
+				}
+				stack = stack[:1]
+			}
+		}
+	}
+
+	if flags&VerifyCleanStack != 0 && len(stack) != 1 {
+		return fmt.Errorf("script: %d elements left on the stack, expected 1", len(stack))
+	}
+	if flags&VerifyWitness != 0 && !hadWitness && len(witness) > 0 {
+		return errors.New("script: unexpected witness")
+	}
+	return nil
+}
+
+// witnessProgram returns the version and program of a segwit scriptPubKey.
+func witnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 || int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	switch op := script[0]; {
+	case op == OP_0:
+		return 0, script[2:], true
+	case op >= OP_1 && op <= OP_16:
+		return int(op-OP_1) + 1, script[2:], true
+	}
+	return 0, nil, false
+}
+
+func isP2SH(script []byte) bool {
+	return len(script) == 23 && script[0] == OP_HASH160 && script[1] == 0x14 && script[22] == OP_EQUAL
+}
+
+// isPushOnly returns if the script only pushes data, which includes OP_1 to
+// OP_16, OP_1NEGATE and OP_RESERVED.
+func isPushOnly(script []byte) bool {
+	ops, err := parseOps(script)
+	if err != nil {
+		return false
+	}
+	for _, o := range ops {
+		if o.op > OP_16 {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyWitnessProgram(witness [][]byte, version int, program []byte, flags ScriptFlags, checker SignatureChecker, isP2SH bool) error {
+	stack := append([][]byte(nil), witness...)
+
+	switch {
+	case version == 0 && len(program) == 32:
+		if len(stack) == 0 {
+			return errors.New("script: empty witness")
+		}
+		witnessScript := stack[len(stack)-1]
+		if !bytes.Equal(Sha256(witnessScript), program) {
+			return errors.New("script: witness script doesn't match the program")
+		}
+		return executeWitnessScript(stack[:len(stack)-1], witnessScript, flags, checker, &engine{version: SigVersionWitnessV0})
+	case version == 0 && len(program) == 20:
+		if len(stack) != 2 {
+			return errors.New("script: P2WPKH witness must have 2 elements")
+		}
+		script := NewBytes([]byte{OP_DUP, OP_HASH160, OP_PUSH_BYTES(20)}, program, []byte{OP_EQUALVERIFY, OP_CHECKSIG})
+		return executeWitnessScript(stack, script, flags, checker, &engine{version: SigVersionWitnessV0})
+	case version == 0:
+		return fmt.Errorf("script: invalid witness v0 program size %d", len(program))
+	case version == 1 && len(program) == 32 && !isP2SH:
+		// Taproot outputs are anyone can spend before its activation.
+		if flags&VerifyTaproot == 0 {
+			return nil
+		}
+		return verifyTaproot(stack, program, flags, checker)
+	}
+
+	if flags&VerifyDiscourageUpgradableWitnessProgram != 0 {
+		return fmt.Errorf("script: witness version %d is reserved for soft forks", version)
+	}
+	return nil
+}
+
+// witnessSize returns the serialized size of a witness.
+func witnessSize(witness [][]byte) int64 {
+	size := len(compactSize(len(witness)))
+	for _, item := range witness {
+		size += len(compactSize(len(item))) + len(item)
+	}
+	return int64(size)
+}
+
+func verifyTaproot(stack [][]byte, program []byte, flags ScriptFlags, checker SignatureChecker) error {
+	if len(stack) == 0 {
+		return errors.New("script: empty witness")
+	}
+	size := witnessSize(stack)
+
+	// The annex is committed by the signatures, it's up to the checker.
+	if len(stack) >= 2 && len(stack[len(stack)-1]) > 0 && stack[len(stack)-1][0] == annexTag {
+		stack = stack[:len(stack)-1]
+	}
+
+	if len(stack) == 1 {
+		if !checker.CheckSchnorrSignature(stack[0], program, nil, 0xffffffff) {
+			return errors.New("script: invalid Taproot key path signature")
+		}
+		return nil
+	}
+
+	control := stack[len(stack)-1]
+	script := stack[len(stack)-2]
+	stack = stack[:len(stack)-2]
+
+	if len(control) < 33 || len(control) > 33+32*maxTapTreeDepth || (len(control)-33)%32 != 0 {
+		return fmt.Errorf("script: invalid control block size %d", len(control))
+	}
+
+	leaf := TapLeaf{Version: control[0] &^ 1, Script: script}
+	leafHash := leaf.Hash()
+	root := leafHash
+	for i := 33; i < len(control); i += 32 {
+		root = tapBranchHash(root, control[i:i+32])
+	}
+
+	outputKey, parity, err := taprootTweak(control[1:33], root)
+	if err != nil || !bytes.Equal(outputKey, program) || parity != control[0]&1 {
+		return errors.New("script: Taproot commitment mismatch")
+	}
+
+	if leaf.Version != TapLeafVersion {
+		if flags&VerifyDiscourageUpgradableTaprootVersion != 0 {
+			return fmt.Errorf("script: leaf version %#x is reserved for soft forks", leaf.Version)
+		}
+		return nil
+	}
+
+	return executeWitnessScript(stack, script, flags, checker, &engine{
+		version:    SigVersionTapscript,
+		leafHash:   leafHash,
+		codeSepPos: 0xffffffff,
+		budget:     size + validationWeightPerSig,
+	})
+}
+
+// isOpSuccess returns if the opcode is an OP_SUCCESSx, which makes any
+// tapscript containing it valid (BIP342).
+func isOpSuccess(op byte) bool {
+	return op == 0x50 || op == 0x62 || (op >= 0x7e && op <= 0x81) ||
+		(op >= 0x83 && op <= 0x86) || (op >= 0x89 && op <= 0x8a) ||
+		(op >= 0x8d && op <= 0x8e) || (op >= 0x95 && op <= 0x99) ||
+		(op >= 0xbb && op <= 0xfe)
+}
+
+// executeWitnessScript runs a witness script, which must leave exactly a
+// true value on the stack. e holds the execution rules.
+func executeWitnessScript(stack [][]byte, script []byte, flags ScriptFlags, checker SignatureChecker, e *engine) error {
+	if e.version == SigVersionTapscript {
+		// OP_SUCCESSx takes precedence over any other failure, even invalid
+		// opcodes following it.
+		ops, err := parseOps(script)
+		for _, o := range ops {
+			if isOpSuccess(o.op) {
+				if flags&VerifyDiscourageOpSuccess != 0 {
+					return fmt.Errorf("script: OP_SUCCESS%d is reserved for soft forks", o.op)
+				}
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(stack) > maxStackSize {
+			return errors.New("script: stack size exceeded")
+		}
+	}
+
+	for _, item := range stack {
+		if len(item) > maxScriptElementSize {
+			return errors.New("script: witness element exceeds the maximum size")
+		}
+	}
+
+	e.flags, e.checker, e.stack = flags, checker, stack
+	if err := e.execute(script); err != nil {
+		return err
+	}
+
+	if len(e.stack) != 1 {
+		return fmt.Errorf("script: %d elements left on the stack, expected 1", len(e.stack))
+	}
+	if !castToBool(e.stack[0]) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+// evalScript runs a legacy script with the given stack.
+func evalScript(stack [][]byte, script []byte, flags ScriptFlags, checker SignatureChecker, version SigVersion) ([][]byte, error) {
+	e := &engine{flags: flags, checker: checker, version: version, stack: stack}
+	if err := e.execute(script); err != nil {
+		return nil, err
+	}
+	return e.stack, nil
+}
+
+// engine executes a single script.
+type engine struct {
+	flags   ScriptFlags
+	checker SignatureChecker
+	version SigVersion
+
+	stack, alt [][]byte
+
+	// Tapscript execution data.
+	leafHash   []byte
+	codeSepPos uint32
+	budget     int64
+}
+
+func (e *engine) push(item []byte) {
+	e.stack = append(e.stack, item)
+}
+
+func (e *engine) pushBool(b bool) {
+	if b {
+		e.push([]byte{1})
+	} else {
+		e.push(nil)
+	}
+}
+
+func (e *engine) pushNum(n int64) {
+	e.push(scriptNum(n))
+}
+
+// top returns the ith element from the top of the stack, starting at 1.
+func (e *engine) top(i int) ([]byte, error) {
+	if i < 1 || i > len(e.stack) {
+		return nil, errInvalidStackOp
+	}
+	return e.stack[len(e.stack)-i], nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	item, err := e.top(1)
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return item, nil
+}
+
+func (e *engine) popBool() (bool, error) {
+	item, err := e.pop()
+	return castToBool(item), err
+}
+
+func (e *engine) popNum() (int64, error) {
+	return e.popNumSize(maxScriptNumSize)
+}
+
+func (e *engine) popNumSize(maxLen int) (int64, error) {
+	item, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return parseScriptNum(item, e.flags&VerifyMinimalData != 0, maxLen)
+}
+
+// checkStackSize fails if the stack holds too many elements. pending are the
+// elements about to be pushed.
+func (e *engine) checkStackSize(pending int) error {
+	if len(e.stack)+len(e.alt)+pending > maxStackSize {
+		return errors.New("script: stack size exceeded")
+	}
+	return nil
+}
+
+func (e *engine) isWitness() bool {
+	return e.version == SigVersionWitnessV0 || e.version == SigVersionTapscript
+}
+
+// parseScriptNum decodes a script number of at most maxLen bytes, which must
+// be minimally encoded if required.
+func parseScriptNum(b []byte, minimal bool, maxLen int) (int64, error) {
+	if len(b) > maxLen {
+		return 0, fmt.Errorf("script: number of %d bytes exceeds %d bytes", len(b), maxLen)
+	}
+
+	// The most significant byte can only be zero, or 0x80 for negative
+	// numbers, when the sign bit is needed.
+	if minimal && len(b) > 0 && b[len(b)-1]&0x7f == 0 && (len(b) == 1 || b[len(b)-2]&0x80 == 0) {
+		return 0, errors.New("script: non-minimally encoded number")
+	}
+	return decodeScriptNum(b), nil
+}
+
+// castToBool returns if an element is true, which is any non zero value
+// other than negative zero.
+func castToBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			return i != len(b)-1 || v != 0x80
+		}
+	}
+	return false
+}
+
+// isMinimalPush returns if data is pushed with the smallest opcode.
+func isMinimalPush(o scriptOp) bool {
+	n := len(o.data)
+	switch {
+	case n == 0:
+		return o.op == OP_0
+	case n == 1 && o.data[0] >= 1 && o.data[0] <= 16:
+		return false
+	case n == 1 && o.data[0] == 0x81:
+		return false
+	case n < OP_PUSHDATA1:
+		return int(o.op) == n
+	case n <= 0xff:
+		return o.op == OP_PUSHDATA1
+	case n <= 0xffff:
+		return o.op == OP_PUSHDATA2
+	}
+	return true
+}
+
+// isDisabled returns if the opcode is disabled in legacy and segwit v0
+// scripts, which fails even if not executed.
+func isDisabled(op byte) bool {
+	switch op {
+	case OP_CAT, OP_SUBSTR, OP_LEFT, OP_RIGHT, OP_INVERT, OP_AND, OP_OR, OP_XOR,
+		OP_2MUL, OP_2DIV, OP_MUL, OP_DIV, OP_MOD, OP_LSHIFT, OP_RSHIFT:
+		return true
+	}
+	return false
+}
+
+func (e *engine) execute(script []byte) error {
+	if e.version != SigVersionTapscript && len(script) > maxScriptSize {
+		return errors.New("script: script size exceeded")
+	}
+
+	ops, err := parseOps(script)
+	if err != nil {
+		return err
+	}
+
+	// conds holds the state of the nested OP_IFs, the current branch is
+	// executed if all of them are true.
+	var conds []bool
+	executing := func() bool {
+		for _, c := range conds {
+			if !c {
+				return false
+			}
+		}
+		return true
+	}
+
+	opCount := 0
+	codeSepEnd := 0
+	for pos, o := range ops {
+		exec := executing()
+
+		if len(o.data) > maxScriptElementSize {
+			return errors.New("script: push exceeds the maximum element size")
+		}
+		if e.version == SigVersionBase || e.version == SigVersionWitnessV0 {
+			if o.op > OP_16 {
+				if opCount++; opCount > maxOpsPerScript {
+					return errors.New("script: operation limit exceeded")
+				}
+			}
+			if isDisabled(o.op) {
+				return fmt.Errorf("script: %s is disabled", OpcodeName(o.op))
+			}
+		}
+
+		switch {
+		case exec && o.isPush():
+			if e.flags&VerifyMinimalData != 0 && !isMinimalPush(o) {
+				return errors.New("script: non-minimal push")
+			}
+			e.push(o.data)
+		case exec || (o.op >= OP_IF && o.op <= OP_ENDIF):
+			switch o.op {
+			case OP_IF, OP_NOTIF:
+				value := false
+				if exec {
+					item, err := e.pop()
+					if err != nil {
+						return errors.New("script: unbalanced conditional")
+					}
+					minimalIf := e.version == SigVersionTapscript ||
+						(e.version == SigVersionWitnessV0 && e.flags&VerifyMinimalIf != 0)
+					if minimalIf && (len(item) > 1 || (len(item) == 1 && item[0] != 1)) {
+						return errors.New("script: OP_IF argument must be empty or 0x01")
+					}
+					value = castToBool(item) == (o.op == OP_IF)
+				}
+				conds = append(conds, value)
+			case OP_ELSE:
+				if len(conds) == 0 {
+					return errors.New("script: unbalanced conditional")
+				}
+				conds[len(conds)-1] = !conds[len(conds)-1]
+			case OP_ENDIF:
+				if len(conds) == 0 {
+					return errors.New("script: unbalanced conditional")
+				}
+				conds = conds[:len(conds)-1]
+			case OP_CODESEPARATOR:
+				codeSepEnd = o.end
+				e.codeSepPos = uint32(pos)
+			case OP_CHECKSIG, OP_CHECKSIGVERIFY, OP_CHECKSIGADD, OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+				if err := e.executeSignature(o.op, script[codeSepEnd:], &opCount); err != nil {
+					return err
+				}
+			default:
+				if err := e.executeOp(o.op); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := e.checkStackSize(0); err != nil {
+			return err
+		}
+	}
+
+	if len(conds) != 0 {
+		return errors.New("script: unbalanced conditional")
+	}
+	return nil
+}
+
+// executeOp executes the opcodes which don't check signatures or change the
+// control flow.
+func (e *engine) executeOp(op byte) error {
+	switch {
+	case op == OP_1NEGATE || (op >= OP_1 && op <= OP_16):
+		e.pushNum(int64(op) - int64(OP_1-1))
+		return nil
+	case op == OP_NOP:
+		return nil
+	case op == OP_NOP1 || (op >= OP_NOP4 && op <= OP_NOP10):
+		return e.upgradableNop(op)
+	case op == OP_CHECKLOCKTIMEVERIFY:
+		// Without its flag the opcode is a NOP, which isn't discouraged as it
+		// was already soft forked.
+		if e.flags&VerifyCheckLockTimeVerify == 0 {
+			return nil
+		}
+		return e.checkLockTime()
+	case op == OP_CHECKSEQUENCEVERIFY:
+		if e.flags&VerifyCheckSequenceVerify == 0 {
+			return nil
+		}
+		return e.checkSequence()
+	case op == OP_VERIFY:
+		ok, err := e.popBool()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("script: OP_VERIFY failed")
+		}
+		return nil
+	case op == OP_RETURN:
+		return errors.New("script: OP_RETURN executed")
+	case op >= OP_TOALTSTACK && op <= OP_TUCK:
+		return e.executeStackOp(op)
+	case op == OP_SIZE:
+		item, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		e.pushNum(int64(len(item)))
+		return nil
+	case op == OP_EQUAL || op == OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		equal := bytes.Equal(a, b)
+		if op == OP_EQUALVERIFY {
+			if !equal {
+				return errors.New("script: OP_EQUALVERIFY failed")
+			}
+			return nil
+		}
+		e.pushBool(equal)
+		return nil
+	case op >= OP_1ADD && op <= OP_WITHIN:
+		return e.executeArithmetic(op)
+	case op >= OP_RIPEMD160 && op <= OP_HASH256:
+		item, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case OP_RIPEMD160:
+			e.push(calcHash(item, ripemd160.New()))
+		case OP_SHA1:
+			h := sha1.Sum(item) // nolint:gosec
+			e.push(h[:])
+		case OP_SHA256:
+			e.push(Sha256(item))
+		case OP_HASH160:
+			e.push(Hash160(item))
+		case OP_HASH256:
+			e.push(Sha256(Sha256(item)))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("script: invalid opcode %s (%#x)", OpcodeName(op), op)
+}
+
+func (e *engine) upgradableNop(op byte) error {
+	if e.flags&VerifyDiscourageUpgradableNops != 0 {
+		return fmt.Errorf("script: %s is reserved for soft forks", OpcodeName(op))
+	}
+	return nil
+}
+
+func (e *engine) checkLockTime() error {
+	item, err := e.top(1)
+	if err != nil {
+		return err
+	}
+
+	// Lock times are up to 5 bytes long to support dates beyond 2038.
+	lockTime, err := parseScriptNum(item, e.flags&VerifyMinimalData != 0, 5)
+	if err != nil {
+		return err
+	}
+	if lockTime < 0 {
+		return errors.New("script: negative lock time")
+	}
+	if !e.checker.CheckLockTime(lockTime) {
+		return errors.New("script: lock time requirement not satisfied")
+	}
+	return nil
+}
+
+func (e *engine) checkSequence() error {
+	item, err := e.top(1)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := parseScriptNum(item, e.flags&VerifyMinimalData != 0, 5)
+	if err != nil {
+		return err
+	}
+	if sequence < 0 {
+		return errors.New("script: negative sequence")
+	}
+
+	// The disable flag turns the opcode into a NOP.
+	if sequence&(1<<31) != 0 {
+		return nil
+	}
+	if !e.checker.CheckSequence(sequence) {
+		return errors.New("script: sequence requirement not satisfied")
+	}
+	return nil
+}
+
+func (e *engine) executeStackOp(op byte) error {
+	// need returns the i topmost elements, failing if there are less.
+	need := func(n int) error {
+		if len(e.stack) < n {
+			return errInvalidStackOp
+		}
+		return nil
+	}
+	// at returns the ith element from the top, starting at 1.
+	at := func(i int) []byte {
+		return e.stack[len(e.stack)-i]
+	}
+	// remove deletes the ith element from the top, starting at 1.
+	remove := func(i int) []byte {
+		item := at(i)
+		idx := len(e.stack) - i
+		e.stack = append(e.stack[:idx], e.stack[idx+1:]...)
+		return item
+	}
+
+	switch op {
+	case OP_TOALTSTACK:
+		item, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.alt = append(e.alt, item)
+	case OP_FROMALTSTACK:
+		if len(e.alt) == 0 {
+			return errors.New("script: invalid altstack operation")
+		}
+		e.push(e.alt[len(e.alt)-1])
+		e.alt = e.alt[:len(e.alt)-1]
+	case OP_2DROP:
+		if err := need(2); err != nil {
+			return err
+		}
+		e.stack = e.stack[:len(e.stack)-2]
+	case OP_2DUP:
+		if err := need(2); err != nil {
+			return err
+		}
+		e.stack = append(e.stack, at(2), at(1))
+	case OP_3DUP:
+		if err := need(3); err != nil {
+			return err
+		}
+		e.stack = append(e.stack, at(3), at(2), at(1))
+	case OP_2OVER:
+		if err := need(4); err != nil {
+			return err
+		}
+		e.stack = append(e.stack, at(4), at(3))
+	case OP_2ROT:
+		if err := need(6); err != nil {
+			return err
+		}
+		a := remove(6)
+		b := remove(5)
+		e.stack = append(e.stack, a, b)
+	case OP_2SWAP:
+		if err := need(4); err != nil {
+			return err
+		}
+		n := len(e.stack)
+		e.stack[n-4], e.stack[n-2] = e.stack[n-2], e.stack[n-4]
+		e.stack[n-3], e.stack[n-1] = e.stack[n-1], e.stack[n-3]
+	case OP_IFDUP:
+		item, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		if castToBool(item) {
+			e.push(item)
+		}
+	case OP_DEPTH:
+		e.pushNum(int64(len(e.stack)))
+	case OP_DROP:
+		if _, err := e.pop(); err != nil {
+			return err
+		}
+	case OP_DUP:
+		item, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		e.push(item)
+	case OP_NIP:
+		if err := need(2); err != nil {
+			return err
+		}
+		remove(2)
+	case OP_OVER:
+		if err := need(2); err != nil {
+			return err
+		}
+		e.push(at(2))
+	case OP_PICK, OP_ROLL:
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		if n < 0 || n >= int64(len(e.stack)) {
+			return errInvalidStackOp
+		}
+
+		item := at(int(n) + 1)
+		if op == OP_ROLL {
+			remove(int(n) + 1)
+		}
+		e.push(item)
+	case OP_ROT:
+		if err := need(3); err != nil {
+			return err
+		}
+		e.push(remove(3))
+	case OP_SWAP:
+		if err := need(2); err != nil {
+			return err
+		}
+		n := len(e.stack)
+		e.stack[n-2], e.stack[n-1] = e.stack[n-1], e.stack[n-2]
+	case OP_TUCK:
+		if err := need(2); err != nil {
+			return err
+		}
+		top := at(1)
+		idx := len(e.stack) - 2
+		e.stack = append(e.stack[:idx], append([][]byte{top}, e.stack[idx:]...)...)
+	default:
+		return fmt.Errorf("script: invalid opcode %s (%#x)", OpcodeName(op), op)
+	}
+
+	return nil
+}
+
+func (e *engine) executeArithmetic(op byte) error {
+	switch op {
+	case OP_1ADD, OP_1SUB, OP_NEGATE, OP_ABS, OP_NOT, OP_0NOTEQUAL:
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case OP_1ADD:
+			n++
+		case OP_1SUB:
+			n--
+		case OP_NEGATE:
+			n = -n
+		case OP_ABS:
+			if n < 0 {
+				n = -n
+			}
+		case OP_NOT:
+			n = boolNum(n == 0)
+		case OP_0NOTEQUAL:
+			n = boolNum(n != 0)
+		}
+		e.pushNum(n)
+		return nil
+	case OP_WITHIN:
+		max, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		min, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		e.pushBool(min <= n && n < max)
+		return nil
+	}
+
+	b, err := e.popNum()
+	if err != nil {
+		return err
+	}
+	a, err := e.popNum()
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	switch op {
+	case OP_ADD:
+		n = a + b
+	case OP_SUB:
+		n = a - b
+	case OP_BOOLAND:
+		n = boolNum(a != 0 && b != 0)
+	case OP_BOOLOR:
+		n = boolNum(a != 0 || b != 0)
+	case OP_NUMEQUAL, OP_NUMEQUALVERIFY:
+		n = boolNum(a == b)
+	case OP_NUMNOTEQUAL:
+		n = boolNum(a != b)
+	case OP_LESSTHAN:
+		n = boolNum(a < b)
+	case OP_GREATERTHAN:
+		n = boolNum(a > b)
+	case OP_LESSTHANOREQUAL:
+		n = boolNum(a <= b)
+	case OP_GREATERTHANOREQUAL:
+		n = boolNum(a >= b)
+	case OP_MIN:
+		n = a
+		if b < a {
+			n = b
+		}
+	case OP_MAX:
+		n = a
+		if b > a {
+			n = b
+		}
+	default:
+		return fmt.Errorf("script: invalid opcode %s (%#x)", OpcodeName(op), op)
+	}
+
+	if op == OP_NUMEQUALVERIFY {
+		if n == 0 {
+			return errors.New("script: OP_NUMEQUALVERIFY failed")
+		}
+		return nil
+	}
+	e.pushNum(n)
+	return nil
+}
+
+func boolNum(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// executeSignature executes the signature checking opcodes. opCount is the
+// number of opcodes executed so far, which OP_CHECKMULTISIG increases by its
+// number of keys.
+func (e *engine) executeSignature(op byte, scriptCode []byte, opCount *int) error {
+	switch op {
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		ok, err := e.checkSig(sig, pubKey, scriptCode)
+		if err != nil {
+			return err
+		}
+		if op == OP_CHECKSIGVERIFY {
+			if !ok {
+				return errors.New("script: OP_CHECKSIGVERIFY failed")
+			}
+			return nil
+		}
+		e.pushBool(ok)
+		return nil
+	case OP_CHECKSIGADD:
+		if e.version != SigVersionTapscript {
+			return fmt.Errorf("script: invalid opcode %s (%#x)", OpcodeName(op), op)
+		}
+
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		ok, err := e.checkSchnorr(sig, pubKey)
+		if err != nil {
+			return err
+		}
+		e.pushNum(n + boolNum(ok))
+		return nil
+	}
+
+	if e.version == SigVersionTapscript {
+		return errors.New("script: OP_CHECKMULTISIG is disabled in tapscript")
+	}
+	return e.checkMultisig(op, scriptCode, opCount)
+}
+
+func (e *engine) checkSig(sig, pubKey, scriptCode []byte) (bool, error) {
+	if e.version == SigVersionTapscript {
+		return e.checkSchnorr(sig, pubKey)
+	}
+
+	// Legacy signatures can't sign themselves, so they are removed from the
+	// signed script.
+	if e.version == SigVersionBase {
+		scriptCode = findAndDelete(scriptCode, pushData(sig))
+	}
+	return e.checkECDSA(sig, pubKey, scriptCode)
+}
+
+func (e *engine) checkECDSA(sig, pubKey, scriptCode []byte) (bool, error) {
+	if err := e.checkSignatureEncoding(sig); err != nil {
+		return false, err
+	}
+	if err := e.checkPubKeyEncoding(pubKey); err != nil {
+		return false, err
+	}
+
+	ok := len(sig) > 0 && e.checker.CheckECDSASignature(sig, pubKey, scriptCode, e.version)
+	if !ok && len(sig) > 0 && e.flags&VerifyNullFail != 0 {
+		return false, errors.New("script: failing signatures must be empty")
+	}
+	return ok, nil
+}
+
+func (e *engine) checkSchnorr(sig, pubKey []byte) (bool, error) {
+	if len(sig) > 0 {
+		if e.budget -= validationWeightPerSig; e.budget < 0 {
+			return false, errors.New("script: validation weight exceeded")
+		}
+	}
+
+	switch {
+	case len(pubKey) == 0:
+		return false, errors.New("script: empty public key")
+	case len(pubKey) == 32:
+		if len(sig) > 0 && !e.checker.CheckSchnorrSignature(sig, pubKey, e.leafHash, e.codeSepPos) {
+			return false, errors.New("script: invalid Schnorr signature")
+		}
+	case e.flags&VerifyDiscourageUpgradablePubKeyType != 0:
+		return false, errors.New("script: public key type is reserved for soft forks")
+	}
+
+	return len(sig) > 0, nil
+}
+
+func (e *engine) checkMultisig(op byte, scriptCode []byte, opCount *int) error {
+	nKeys, err := e.popNum()
+	if err != nil {
+		return err
+	}
+	if nKeys < 0 || nKeys > maxPubKeysPerMulti {
+		return fmt.Errorf("script: invalid number of keys %d", nKeys)
+	}
+	// Each key counts towards the opcodes limit.
+	if *opCount += int(nKeys); *opCount > maxOpsPerScript {
+		return errors.New("script: operation limit exceeded")
+	}
+	if len(e.stack) < int(nKeys) {
+		return errInvalidStackOp
+	}
+	keys := make([][]byte, nKeys)
+	for i := range keys {
+		keys[i], _ = e.pop()
+	}
+
+	nSigs, err := e.popNum()
+	if err != nil {
+		return err
+	}
+	if nSigs < 0 || nSigs > nKeys {
+		return fmt.Errorf("script: invalid number of signatures %d", nSigs)
+	}
+	if len(e.stack) < int(nSigs) {
+		return errInvalidStackOp
+	}
+	sigs := make([][]byte, nSigs)
+	for i := range sigs {
+		sigs[i], _ = e.pop()
+	}
+
+	// Due to an original bug, an extra element is consumed.
+	dummy, err := e.pop()
+	if err != nil {
+		return err
+	}
+
+	if e.version == SigVersionBase {
+		for _, sig := range sigs {
+			scriptCode = findAndDelete(scriptCode, pushData(sig))
+		}
+	}
+
+	// Keys and signatures were popped from the top, so both are in reverse
+	// order and signatures must follow the keys order.
+	success := true
+	for isig, ikey := 0, 0; success && isig < len(sigs); {
+		sig, key := sigs[isig], keys[ikey]
+		if err := e.checkSignatureEncoding(sig); err != nil {
+			return err
+		}
+		if err := e.checkPubKeyEncoding(key); err != nil {
+			return err
+		}
+
+		if len(sig) > 0 && e.checker.CheckECDSASignature(sig, key, scriptCode, e.version) {
+			isig++
+		}
+		ikey++
+
+		// Not enough keys left for the remaining signatures.
+		if len(sigs)-isig > len(keys)-ikey {
+			success = false
+		}
+	}
+
+	if !success && e.flags&VerifyNullFail != 0 {
+		for _, sig := range sigs {
+			if len(sig) > 0 {
+				return errors.New("script: failing signatures must be empty")
+			}
+		}
+	}
+	if e.flags&VerifyNullDummy != 0 && len(dummy) > 0 {
+		return errors.New("script: OP_CHECKMULTISIG dummy must be empty")
+	}
+
+	if op == OP_CHECKMULTISIGVERIFY {
+		if !success {
+			return errors.New("script: OP_CHECKMULTISIGVERIFY failed")
+		}
+		return nil
+	}
+	e.pushBool(success)
+	return nil
+}
+
+// findAndDelete removes the occurrences of the pattern opcode from script.
+func findAndDelete(script, pattern []byte) []byte {
+	ops, _ := parseOps(script)
+
+	var result []byte
+	start := 0
+	for _, o := range ops {
+		if !bytes.Equal(script[start:o.end], pattern) {
+			result = append(result, script[start:o.end]...)
+		}
+		start = o.end
+	}
+	return append(result, script[start:]...)
+}
+
+func (e *engine) checkSignatureEncoding(sig []byte) error {
+	if len(sig) == 0 {
+		return nil
+	}
+
+	if e.flags&(VerifyDERSignatures|VerifyLowS|VerifyStrictEncoding) != 0 && !isValidDER(sig) {
+		return errors.New("script: non canonical DER signature")
+	}
+	if e.flags&VerifyLowS != 0 && !isLowS(sig) {
+		return errors.New("script: signature S value is too high")
+	}
+	if e.flags&VerifyStrictEncoding != 0 {
+		if hashType := sig[len(sig)-1] &^ 0x80; hashType < 1 || hashType > 3 {
+			return fmt.Errorf("script: invalid sighash type %#x", sig[len(sig)-1])
+		}
+	}
+	return nil
+}
+
+func (e *engine) checkPubKeyEncoding(pubKey []byte) error {
+	compressed := len(pubKey) == 33 && (pubKey[0] == 0x02 || pubKey[0] == 0x03)
+	uncompressed := len(pubKey) == 65 && pubKey[0] == 0x04
+
+	if e.flags&VerifyStrictEncoding != 0 && !compressed && !uncompressed {
+		return errors.New("script: invalid public key encoding")
+	}
+	if e.flags&VerifyWitnessPubKeyType != 0 && e.version == SigVersionWitnessV0 && !compressed {
+		return errors.New("script: segwit public keys must be compressed")
+	}
+	return nil
+}
+
+// isValidDER returns if the signature, followed by its sighash type, is
+// strictly DER encoded as defined by BIP66.
+func isValidDER(sig []byte) bool {
+	// 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S] [sighash]
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+
+	lenR := int(sig[3])
+	if 5+lenR >= len(sig) {
+		return false
+	}
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+7 != len(sig) {
+		return false
+	}
+
+	for _, n := range []struct{ start, size int }{{4, lenR}, {6 + lenR, lenS}} {
+		if sig[n.start-2] != 0x02 || n.size == 0 {
+			return false
+		}
+		// Numbers can't be negative nor padded with zeros.
+		if sig[n.start]&0x80 != 0 {
+			return false
+		}
+		if n.size > 1 && sig[n.start] == 0 && sig[n.start+1]&0x80 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isLowS returns if the S value of a DER signature is at most half the curve
+// order.
+func isLowS(sig []byte) bool {
+	lenR := int(sig[3])
+	lenS := int(sig[5+lenR])
+	s := new(big.Int).SetBytes(sig[6+lenR : 6+lenR+lenS])
+	halfOrder := new(big.Int).Rsh(btcec.S256().N, 1)
+	return s.Cmp(halfOrder) <= 0
+}
@@ -0,0 +1,409 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChecker verifies ECDSA signatures of a fixed message and accepts the
+// Schnorr signatures registered for each key.
+type testChecker struct {
+	schnorr  map[string][]byte
+	lockTime int64
+	sequence int64
+}
+
+var testSigHash = Sha256([]byte("go-wallet"))
+
+func (c *testChecker) CheckECDSASignature(sig, pubKey, scriptCode []byte, version SigVersion) bool {
+	s, err := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+	if err != nil {
+		return false
+	}
+	key, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return false
+	}
+	return s.Verify(testSigHash, key)
+}
+
+func (c *testChecker) CheckSchnorrSignature(sig, pubKey, leafHash []byte, codeSepPos uint32) bool {
+	expected, ok := c.schnorr[hex.EncodeToString(pubKey)]
+	return ok && bytes.Equal(sig, expected)
+}
+
+func (c *testChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.lockTime
+}
+
+func (c *testChecker) CheckSequence(sequence int64) bool {
+	return sequence <= c.sequence
+}
+
+type testKey struct {
+	priv *btcec.PrivateKey
+	pub  []byte
+	// xOnly is the hex x-only public key.
+	xOnly string
+}
+
+func newTestKey(b byte) testKey {
+	priv, pub := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{b}, 32))
+	return testKey{priv: priv, pub: pub.SerializeCompressed(), xOnly: hex.EncodeToString(pub.SerializeCompressed()[1:])}
+}
+
+func (k testKey) hex() string {
+	return hex.EncodeToString(k.pub)
+}
+
+// sign returns a SIGHASH_ALL ECDSA signature.
+func (k testKey) sign() []byte {
+	sig, err := k.priv.Sign(testSigHash)
+	if err != nil {
+		panic(err)
+	}
+	return append(sig.Serialize(), 0x01)
+}
+
+// schnorr returns a fake Schnorr signature of the key.
+func (k testKey) schnorr() []byte {
+	return bytes.Repeat(k.pub[1:2], 64)
+}
+
+func TestVerifyScript(t *testing.T) {
+	keyA, keyB, keyC := newTestKey(1), newTestKey(2), newTestKey(3)
+	checker := &testChecker{
+		schnorr:  map[string][]byte{},
+		sequence: 1000,
+	}
+	for _, k := range []testKey{keyA, keyB, keyC} {
+		checker.schnorr[k.xOnly] = k.schnorr()
+	}
+
+	multiDesc := "multi(2," + keyA.hex() + "," + keyB.hex() + "," + keyC.hex() + ")"
+	multi, err := Parse(multiDesc)
+	require.NoError(t, err)
+	wshMulti, err := Parse("wsh(" + multiDesc + ")")
+	require.NoError(t, err)
+	orDDesc := "or_d(pk(" + keyA.hex() + "),and_v(v:pk(" + keyB.hex() + "),older(1000)))"
+	orD, err := ParseMiniscript(orDDesc)
+	require.NoError(t, err)
+	orDScript, err := orD.Eval()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		desc      string
+		scriptSig [][]byte
+		witness   [][]byte
+		err       bool
+	}{
+		{
+			name:      "pkh",
+			desc:      "pkh(" + keyA.hex() + ")",
+			scriptSig: [][]byte{keyA.sign(), keyA.pub},
+		},
+		{
+			name:      "pkh wrong key",
+			desc:      "pkh(" + keyA.hex() + ")",
+			scriptSig: [][]byte{keyB.sign(), keyB.pub},
+			err:       true,
+		},
+		{
+			name:      "pkh wrong signature",
+			desc:      "pkh(" + keyA.hex() + ")",
+			scriptSig: [][]byte{keyB.sign(), keyA.pub},
+			err:       true,
+		},
+		{
+			name:    "wpkh",
+			desc:    "wpkh(" + keyA.hex() + ")",
+			witness: [][]byte{keyA.sign(), keyA.pub},
+		},
+		{
+			name:      "wpkh with scriptSig",
+			desc:      "wpkh(" + keyA.hex() + ")",
+			scriptSig: [][]byte{keyA.sign()},
+			witness:   [][]byte{keyA.sign(), keyA.pub},
+			err:       true,
+		},
+		{
+			name:      "sh(multi)",
+			desc:      "sh(" + multiDesc + ")",
+			scriptSig: [][]byte{nil, keyA.sign(), keyC.sign(), multi.Bytes()},
+		},
+		{
+			name:      "sh(multi) unordered signatures",
+			desc:      "sh(" + multiDesc + ")",
+			scriptSig: [][]byte{nil, keyC.sign(), keyA.sign(), multi.Bytes()},
+			err:       true,
+		},
+		{
+			name:      "sh(multi) non null dummy",
+			desc:      "sh(" + multiDesc + ")",
+			scriptSig: [][]byte{{1}, keyA.sign(), keyC.sign(), multi.Bytes()},
+			err:       true,
+		},
+		{
+			name:      "sh(wsh(multi))",
+			desc:      "sh(wsh(" + multiDesc + "))",
+			scriptSig: [][]byte{wshMulti.Bytes()},
+			witness:   [][]byte{nil, keyB.sign(), keyC.sign(), multi.Bytes()},
+		},
+		{
+			name:      "sh(wsh(multi)) missing signature",
+			desc:      "sh(wsh(" + multiDesc + "))",
+			scriptSig: [][]byte{wshMulti.Bytes()},
+			witness:   [][]byte{nil, nil, keyC.sign(), multi.Bytes()},
+			err:       true,
+		},
+		{
+			name:    "miniscript first branch",
+			desc:    "wsh(" + orDDesc + ")",
+			witness: [][]byte{keyA.sign(), orDScript.Bytes()},
+		},
+		{
+			name:    "miniscript timelocked branch",
+			desc:    "wsh(" + orDDesc + ")",
+			witness: [][]byte{keyB.sign(), nil, orDScript.Bytes()},
+		},
+		{
+			name:    "miniscript wrong witness script",
+			desc:    "wsh(" + orDDesc + ")",
+			witness: [][]byte{keyA.sign(), multi.Bytes()},
+			err:     true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := Parse(test.desc)
+			require.NoError(t, err)
+
+			var scriptSig []byte
+			for _, item := range test.scriptSig {
+				scriptSig = append(scriptSig, pushData(item)...)
+			}
+
+			err = script.Verify(scriptSig, test.witness, StandardVerifyFlags, checker)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyTimelock(t *testing.T) {
+	keyA, keyB := newTestKey(1), newTestKey(2)
+	ms, err := ParseMiniscript("or_d(pk(" + keyA.hex() + "),and_v(v:pk(" + keyB.hex() + "),older(1000)))")
+	require.NoError(t, err)
+	witnessScript, err := ms.Eval()
+	require.NoError(t, err)
+	script, err := Wsh(ms).Eval()
+	require.NoError(t, err)
+
+	witness := [][]byte{keyB.sign(), nil, witnessScript.Bytes()}
+	assert.NoError(t, script.Verify(nil, witness, StandardVerifyFlags, &testChecker{sequence: 1000}))
+	assert.Error(t, script.Verify(nil, witness, StandardVerifyFlags, &testChecker{sequence: 999}))
+}
+
+func TestVerifyTaproot(t *testing.T) {
+	internal, keyA, keyB := newTestKey(1), newTestKey(2), newTestKey(3)
+
+	script, err := Parse("tr(" + internal.xOnly + ",{pk(" + keyA.xOnly + "),multi_a(2," + keyA.xOnly + "," + keyB.xOnly + ")})")
+	require.NoError(t, err)
+	tr := script.Taproot()
+	leaves := tr.Leaves()
+	require.Len(t, leaves, 2)
+
+	checker := &testChecker{schnorr: map[string][]byte{
+		hex.EncodeToString(tr.OutputKey): internal.schnorr(),
+		keyA.xOnly:                       keyA.schnorr(),
+		keyB.xOnly:                       keyB.schnorr(),
+	}}
+
+	cb0, err := tr.ControlBlock(leaves[0])
+	require.NoError(t, err)
+	cb1, err := tr.ControlBlock(leaves[1])
+	require.NoError(t, err)
+	badCb := append([]byte(nil), cb0...)
+	badCb[len(badCb)-1] ^= 1
+
+	testCases := []struct {
+		name    string
+		witness [][]byte
+		err     bool
+	}{
+		{"key path", [][]byte{internal.schnorr()}, false},
+		{"key path with annex", [][]byte{internal.schnorr(), {annexTag}}, false},
+		{"key path wrong signature", [][]byte{keyA.schnorr()}, true},
+		{"pk leaf", [][]byte{keyA.schnorr(), leaves[0].Script, cb0}, false},
+		{"pk leaf wrong signature", [][]byte{keyB.schnorr(), leaves[0].Script, cb0}, true},
+		{"pk leaf wrong control block", [][]byte{keyA.schnorr(), leaves[0].Script, badCb}, true},
+		{"pk leaf wrong leaf", [][]byte{keyA.schnorr(), leaves[0].Script, cb1}, true},
+		{"multi_a leaf", [][]byte{keyB.schnorr(), keyA.schnorr(), leaves[1].Script, cb1}, false},
+		{"multi_a leaf missing signature", [][]byte{nil, keyA.schnorr(), leaves[1].Script, cb1}, true},
+		{"empty witness", nil, true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			err := script.Verify(nil, test.witness, StandardVerifyFlags, checker)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyOpSuccess(t *testing.T) {
+	internal := newTestKey(1)
+	leaf := TapLeaf{Version: TapLeafVersion, Script: []byte{0x50, OP_RETURN}}
+	outputKey, parity, err := taprootTweak(mustDecodeHex(internal.xOnly), leaf.Hash())
+	require.NoError(t, err)
+
+	scriptPubKey := NewBytes([]byte{OP_1, OP_PUSH_BYTES(32)}, outputKey)
+	witness := [][]byte{leaf.Script, NewBytes([]byte{TapLeafVersion | parity}, mustDecodeHex(internal.xOnly))}
+
+	assert.NoError(t, VerifyScript(nil, scriptPubKey, witness, ConsensusVerifyFlags, &testChecker{}))
+	assert.Error(t, VerifyScript(nil, scriptPubKey, witness, StandardVerifyFlags, &testChecker{}))
+}
+
+func TestEvalScript(t *testing.T) {
+	testCases := []struct {
+		asm      string
+		standard bool
+		err      bool
+	}{
+		{asm: "1 2 OP_ADD 3 OP_EQUAL", standard: true},
+		{asm: "1 2 OP_SUB -1 OP_NUMEQUAL", standard: true},
+		{asm: "5 0 10 OP_WITHIN", standard: true},
+		{asm: "1 2 3 OP_ROT 1 OP_EQUALVERIFY 3 OP_EQUALVERIFY 2 OP_EQUAL", standard: true},
+		{asm: "1 2 OP_TUCK OP_DEPTH 3 OP_EQUALVERIFY OP_2DROP", standard: true},
+		{asm: "1 2 3 4 OP_2SWAP OP_DROP OP_DROP OP_DROP", standard: true},
+		{asm: "1 2 3 2 OP_PICK 1 OP_EQUALVERIFY OP_2DROP", standard: true},
+		{asm: "1 OP_TOALTSTACK OP_FROMALTSTACK", standard: true},
+		{asm: "1 OP_IF 1 OP_ELSE 0 OP_ENDIF", standard: true},
+		{asm: "0 OP_NOTIF 1 OP_ENDIF", standard: true},
+		{asm: "abcd OP_SHA256 123d4c7ef2d1600a1b3a0f6addc60a10f05a3495c9409f2ecbf4cc095d000a6b OP_EQUAL", standard: true},
+		{asm: "abcd OP_SIZE 2 OP_EQUALVERIFY OP_DROP 1", standard: true},
+		{asm: "0", standard: true, err: true},
+		{asm: "OP_RETURN", standard: true, err: true},
+		{asm: "1 OP_IF", standard: true, err: true},
+		{asm: "OP_ENDIF 1", standard: true, err: true},
+		{asm: "0 OP_IF OP_CAT OP_ENDIF 1", standard: true, err: true},
+		{asm: "0 OP_IF OP_VERIF OP_ENDIF 1", standard: true, err: true},
+		{asm: "1 0x0101", standard: true, err: true},
+		{asm: "0x0180 OP_NOT", standard: true, err: true},
+		{asm: "OP_DROP 1", standard: true, err: true},
+		{asm: "0 OP_VERIFY 1", standard: true, err: true},
+		{asm: "OP_NOP4 1", standard: true, err: true},
+		// Standardness rules.
+		{asm: "0x0101"},
+		{asm: "0x0180 OP_NOT"},
+		{asm: "OP_NOP4 1"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.asm, func(t *testing.T) {
+			script, err := Assemble(test.asm)
+			require.NoError(t, err)
+
+			flags := ConsensusVerifyFlags
+			if test.standard {
+				flags = StandardVerifyFlags &^ VerifyCleanStack
+			}
+
+			err = VerifyScript(nil, script, nil, flags, &testChecker{})
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if !test.standard {
+				assert.Error(t, VerifyScript(nil, script, nil, StandardVerifyFlags&^VerifyCleanStack, &testChecker{}))
+			}
+		})
+	}
+}
+
+func TestEvalScriptLimits(t *testing.T) {
+	// 202 non push opcodes exceed the operation limit.
+	script, err := Assemble("1" + strings.Repeat(" OP_NOP", 202))
+	require.NoError(t, err)
+	assert.Error(t, VerifyScript(nil, script, nil, ConsensusVerifyFlags, &testChecker{}))
+
+	// 1001 stack elements exceed the stack size.
+	script, err = Assemble(strings.Repeat("1 ", 1001))
+	require.NoError(t, err)
+	assert.Error(t, VerifyScript(nil, script, nil, ConsensusVerifyFlags, &testChecker{}))
+
+	// Pushes can't exceed 520 bytes.
+	script = NewBytes(pushData(make([]byte, 521)), []byte{OP_DROP, OP_1})
+	assert.Error(t, VerifyScript(nil, script, nil, ConsensusVerifyFlags, &testChecker{}))
+}
+
+func TestEvalScriptMultisigOpsLimit(t *testing.T) {
+	// The 20 keys of OP_CHECKMULTISIG count towards the operation limit, so
+	// 180 OP_NOPs reach the 201 limit and 181 exceed it.
+	keys := strings.TrimSpace(strings.Repeat(newTestKey(1).hex()+" ", 20))
+	multisig := func(nops int) []byte {
+		script, err := Assemble("0 0" + strings.Repeat(" OP_NOP", nops) + " " + keys + " 20 OP_CHECKMULTISIG")
+		require.NoError(t, err)
+		return script
+	}
+
+	assert.NoError(t, VerifyScript(nil, multisig(180), nil, ConsensusVerifyFlags, &testChecker{}))
+	err := VerifyScript(nil, multisig(181), nil, ConsensusVerifyFlags, &testChecker{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "operation limit exceeded")
+}
+
+func TestVerifyInactiveSoftForks(t *testing.T) {
+	flags := StandardVerifyFlags &^ VerifyCleanStack
+
+	// Without their flags, OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY
+	// are NOPs, even when upgradable NOPs are discouraged.
+	for _, asm := range []string{"1 OP_CHECKLOCKTIMEVERIFY", "1 OP_CHECKSEQUENCEVERIFY"} {
+		script, err := Assemble(asm)
+		require.NoError(t, err)
+
+		assert.Error(t, VerifyScript(nil, script, nil, flags, &testChecker{}), asm)
+		inactive := flags &^ VerifyCheckLockTimeVerify &^ VerifyCheckSequenceVerify
+		assert.NoError(t, VerifyScript(nil, script, nil, inactive, &testChecker{}), asm)
+	}
+
+	// Without the Taproot flag, v1 programs are anyone can spend even when
+	// upgradable witness programs are discouraged.
+	script, err := Parse("tr(" + newTestKey(1).xOnly + ")")
+	require.NoError(t, err)
+	assert.Error(t, script.Verify(nil, nil, StandardVerifyFlags, &testChecker{}))
+	assert.NoError(t, script.Verify(nil, nil, StandardVerifyFlags&^VerifyTaproot, &testChecker{}))
+}
+
+func TestIsValidDER(t *testing.T) {
+	sig := newTestKey(1).sign()
+	assert.True(t, isValidDER(sig))
+	assert.True(t, isLowS(sig))
+
+	for _, mutate := range []func([]byte){
+		func(b []byte) { b[0] = 0x31 },
+		func(b []byte) { b[1]++ },
+		func(b []byte) { b[2] = 0x03 },
+		func(b []byte) { b[4] |= 0x80 },
+	} {
+		bad := append([]byte(nil), sig...)
+		mutate(bad)
+		assert.False(t, isValidDER(bad))
+	}
+}
@@ -1,13 +1,15 @@
 package script
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/btcsuite/btcutil/hdkeychain"
 )
 
@@ -20,7 +22,45 @@ type PubKey struct {
 	key []byte
 }
 
+// NewPubKey parses a hex encoded secp256k1 public key, either compressed (33
+// bytes) or uncompressed (65 bytes).
 func NewPubKey(s string) (*PubKey, error) {
+	key, err := decodeKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(key) == 33 && (key[0] == 0x02 || key[0] == 0x03):
+	case len(key) == 65 && key[0] == 0x04:
+	default:
+		return nil, fmt.Errorf("invalid public key '%s'", s)
+	}
+
+	if _, err := parsePubKey(key); err != nil {
+		return nil, fmt.Errorf("invalid public key '%s': %w", s, err)
+	}
+	return &PubKey{key}, nil
+}
+
+// NewXOnlyPubKey parses a hex encoded BIP340 x-only (32 bytes) public key,
+// which is only valid in Taproot descriptors.
+func NewXOnlyPubKey(s string) (*PubKey, error) {
+	key, err := decodeKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid x-only public key '%s'", s)
+	}
+	if _, err := liftX(key); err != nil {
+		return nil, fmt.Errorf("invalid x-only public key '%s': %w", s, err)
+	}
+	return &PubKey{key}, nil
+}
+
+func decodeKey(s string) ([]byte, error) {
 	if s == "" {
 		return nil, errors.New("pubkey can't be empty")
 	}
@@ -29,25 +69,187 @@ func NewPubKey(s string) (*PubKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid key format: %w", err)
 	}
-	return &PubKey{key}, nil
+	return key, nil
 }
 
 func (pk *PubKey) Bytes() []byte  { return pk.key }
 func (pk *PubKey) String() string { return hex.EncodeToString(pk.key) }
 
+// IsCompressed returns if the key is a 33 bytes compressed key.
+func (pk *PubKey) IsCompressed() bool { return len(pk.key) == 33 }
+
+// IsXOnly returns if the key is a 32 bytes x-only key.
+func (pk *PubKey) IsXOnly() bool { return len(pk.key) == 32 }
+
+// PrivKey is a private key encoded in WIF.
+type PrivKey struct {
+	wif *btcutil.WIF
+}
+
+func NewPrivKey(s string) (*PrivKey, error) {
+	wif, err := btcutil.DecodeWIF(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WIF key: %w", err)
+	}
+
+	// The prefix must be the one of a registered network.
+	if prefix := base58.Decode(s)[0]; !isWIFPrefix(prefix) {
+		return nil, fmt.Errorf("invalid WIF key: unknown prefix 0x%02x", prefix)
+	}
+	return &PrivKey{wif}, nil
+}
+
+func (pk *PrivKey) Bytes() []byte  { return pk.wif.PrivKey.Serialize() }
+func (pk *PrivKey) String() string { return pk.wif.String() }
+
+// PubKey returns the public key, which is compressed unless the WIF says
+// otherwise.
+func (pk *PrivKey) PubKey() *PubKey {
+	return &PubKey{key: pk.wif.SerializePubKey()}
+}
+
+// parseKey parses a hex public key, which can be x-only, or a WIF private
+// key.
+func parseKey(s string) (*PubKey, error) {
+	if priv, err := NewPrivKey(s); err == nil {
+		return priv.PubKey(), nil
+	}
+	if len(s) == 64 {
+		return NewXOnlyPubKey(s)
+	}
+	return NewPubKey(s)
+}
+
+// xOnly returns the 32 bytes x-only (BIP340) encoding of a compressed or
+// already x-only public key.
+func xOnly(key []byte) ([]byte, error) {
+	switch len(key) {
+	case 32:
+		return key, nil
+	case 33:
+		return key[1:], nil
+	case 65:
+		return nil, errors.New("uncompressed keys are not allowed in tr()")
+	}
+	return nil, fmt.Errorf("invalid x-only key length %d", len(key))
+}
+
 type XPub struct {
 	key *hdkeychain.ExtendedKey
+	// wildcard determines the children derived by Child.
+	wildcard wildcard
 }
 
 var (
-	// keyOriginRegexp matches a key origin
-	keyOriginRegexp = regexp.MustCompile(`\[[0-9a-fA-F]{8}.*?\]`)
 	// xpubExprRegexp matches a Xpub and it's children derivation path
 	xpubExprRegexp = regexp.MustCompile(`(\w+)(\/.+)?`)
 )
 
+// KeyOrigin is the origin of a key: the fingerprint of the master key and the
+// derivation path from it.
+type KeyOrigin struct {
+	Fingerprint [4]byte
+	Path        DerivationPath
+}
+
+func (o *KeyOrigin) String() string {
+	return "[" + hex.EncodeToString(o.Fingerprint[:]) + o.Path.levels() + "]"
+}
+
+// splitKeyOrigin splits a key expression into its `[fingerprint/path]` origin,
+// which is nil if not present, and the key.
+func splitKeyOrigin(s string) (*KeyOrigin, string, error) {
+	if !strings.HasPrefix(s, "[") {
+		return nil, s, nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return nil, "", errors.New("key origin: missing ']'")
+	}
+
+	levels := strings.SplitN(s[1:end], "/", 2)
+	fingerprint, err := hex.DecodeString(levels[0])
+	if err != nil || len(fingerprint) != 4 {
+		return nil, "", fmt.Errorf("key origin: invalid fingerprint '%s'", levels[0])
+	}
+
+	origin := &KeyOrigin{}
+	copy(origin.Fingerprint[:], fingerprint)
+	if len(levels) == 2 {
+		path, err := ParseDerivationPath("m/" + levels[1])
+		if err != nil {
+			return nil, "", fmt.Errorf("key origin: %w", err)
+		}
+		origin.Path = path
+	}
+
+	return origin, s[end+1:], nil
+}
+
 func trimKeyOrigin(s string) string {
-	return keyOriginRegexp.ReplaceAllString(s, "")
+	if _, key, err := splitKeyOrigin(s); err == nil {
+		return key
+	}
+	return s
+}
+
+// evalKey returns the public key of a key expression, deriving it if it's an
+// extended key, along with its origin. Extended keys without an explicit
+// origin use their own fingerprint.
+func evalKey(s string) (*PubKey, *KeyOrigin, error) {
+	origin, s, err := splitKeyOrigin(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !IsXPub(s) {
+		pub, err := parseKey(s)
+		return pub, origin, err
+	}
+
+	expr, err := parseXpubExpr(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	xpub, err := newXPub(expr.xpub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if origin == nil {
+		origin = &KeyOrigin{Fingerprint: xpub.fingerprint()}
+	} else {
+		origin = &KeyOrigin{
+			Fingerprint: origin.Fingerprint,
+			Path:        append(DerivationPath(nil), origin.Path...),
+		}
+	}
+
+	path, w, err := parseKeyPath(expr.children)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ranged keys which haven't been expanded evaluate to their parent key,
+	// as long as its children can be derived.
+	if w == wildcardHardened && !xpub.key.IsPrivate() {
+		return nil, nil, errors.New("can't derive hardened wildcard from a public key")
+	}
+
+	key, err := deriveKey(xpub.key, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	origin.Path = append(origin.Path, path...)
+
+	pub, err := key.ECPubKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &PubKey{key: pub.SerializeCompressed()}, origin, nil
 }
 
 type xpubExpr struct {
@@ -69,17 +271,110 @@ func parseXpubExpr(s string) (*xpubExpr, error) {
 	}, nil
 }
 
-// IsXPub returns if a string looks like an XPub or not
+// IsXPub returns if a string looks like an extended key or not, either public
+// or private. Besides the usual prefixes, the version bytes of registered
+// networks are recognized.
 func IsXPub(s string) bool {
-	marks := []string{"xpub", "vpub", "xpriv", "tprv", "ypub", "yprv", "zpub", "zprv"}
+	marks := []string{
+		"xpub", "xprv", "tpub", "tprv",
+		"ypub", "yprv", "upub", "uprv",
+		"zpub", "zprv", "vpub", "vprv",
+	}
 	for _, mark := range marks {
-		if strings.Contains(s, mark) {
+		if strings.HasPrefix(s, mark) {
 			return true
 		}
 	}
-	return false
+
+	// Serialized extended keys are 78 bytes plus a 4 bytes checksum.
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	payload := base58.Decode(s)
+	return len(payload) == 82 && isHDKeyID(payload[:4])
+}
+
+// publicKeyExpr returns the key expression with its private key replaced by
+// the public one. Hardened levels following an extended private key are
+// derived and moved into the key origin, so that the public key can still
+// derive the remaining levels. Keys without private material are returned as
+// they are.
+func publicKeyExpr(s string) (string, error) {
+	origin, key, err := splitKeyOrigin(s)
+	if err != nil {
+		return "", err
+	}
+	prefix := s[:len(s)-len(key)]
+
+	if !IsXPub(key) {
+		priv, err := NewPrivKey(key)
+		if err != nil {
+			return s, nil
+		}
+		return prefix + priv.PubKey().String(), nil
+	}
+
+	expr, err := parseXpubExpr(key)
+	if err != nil {
+		return "", err
+	}
+
+	xprv, err := newXPub(expr.xpub)
+	if err != nil {
+		return "", err
+	}
+	if !xprv.key.IsPrivate() {
+		return s, nil
+	}
+
+	var levels []string
+	if expr.children != "" {
+		levels = strings.Split(strings.TrimPrefix(expr.children, "/"), "/")
+	}
+
+	var derived DerivationPath
+	for len(levels) > 0 && isHardened(levels[0]) && !strings.HasPrefix(levels[0], "*") {
+		i, err := parsePathLevel(levels[0])
+		if err != nil {
+			return "", err
+		}
+		derived = append(derived, i)
+		levels = levels[1:]
+	}
+	for _, level := range levels {
+		if strings.ContainsAny(level, "'hH") {
+			return "", fmt.Errorf("can't derive hardened level '%s' from a public key", level)
+		}
+	}
+
+	if len(derived) > 0 {
+		if origin == nil {
+			origin = &KeyOrigin{Fingerprint: xprv.fingerprint()}
+		}
+		key, err := deriveKey(xprv.key, derived)
+		if err != nil {
+			return "", err
+		}
+		xprv = &XPub{key: key}
+		origin.Path = append(origin.Path, derived...)
+		prefix = origin.String()
+	}
+
+	xpub, err := neuter(xprv.key)
+	if err != nil {
+		return "", err
+	}
+
+	pub := prefix + xpub.String()
+	for _, level := range levels {
+		pub += "/" + level
+	}
+	return pub, nil
 }
 
+// NewXPub parses an extended key followed by an optional derivation path,
+// e.g. `xpub/0/*`. Ranged keys derive their children with Child, hardened
+// ones (`/*'`) only if the key is private.
 func NewXPub(s string) (*XPub, error) {
 	expr, err := parseXpubExpr(s)
 	if err != nil {
@@ -91,10 +386,16 @@ func NewXPub(s string) (*XPub, error) {
 		return nil, err
 	}
 
-	if path := expr.children; path != "" {
-		return xpub.Derive("m" + path)
+	path, w, err := parseKeyPath(expr.children)
+	if err != nil {
+		return nil, err
 	}
 
+	if xpub.key, err = deriveKey(xpub.key, path); err != nil {
+		return nil, err
+	}
+	xpub.wildcard = w
+
 	return xpub, nil
 }
 
@@ -107,65 +408,28 @@ func newXPub(s string) (*XPub, error) {
 	return &XPub{key: key}, nil
 }
 
-func parsePath(path string, fn func(uint32) error) error {
-	if !strings.HasPrefix(path, "m/") {
-		return errors.New("xpub: invalid path prefix")
-	}
-	path = strings.TrimPrefix(path, "m/")
-
-	// Hardened levels can be defined as `'`, `h` or `H` so unify them into `'`
-	path = strings.Map(func(r rune) rune {
-		if r == 'h' || r == 'H' {
-			return '\''
-		}
-		return r
-	}, path)
-
-	levels := strings.Split(path, "/")
-	for _, level := range levels {
-		var v uint32
-
-		// Verify if the level is hardened
-		if strings.HasSuffix(level, "'") {
-			v = 0x80000000
-			level = strings.TrimSuffix(level, "'")
-		}
-
-		if level == "*" {
-			continue
-		}
-
-		atoi, err := strconv.Atoi(level)
-		if err != nil {
-			return err
-		}
-
-		v += uint32(atoi)
-
-		if err := fn(v); err != nil {
-			return err
-		}
+func (xpub *XPub) Derive(path string) (*XPub, error) {
+	p, err := ParseDerivationPath(path)
+	if err != nil {
+		return xpub, err
 	}
 
-	return nil
-}
-
-func (xpub *XPub) Derive(path string) (*XPub, error) {
-	key := xpub.key
-	err := parsePath(path, func(i uint32) error {
-		var err error
-		key, err = key.Derive(i)
-		return err
-	})
+	key, err := deriveKey(xpub.key, p)
 	if err != nil {
 		return xpub, err
 	}
 
-	return &XPub{key: key}, nil
+	return &XPub{key: key, wildcard: xpub.wildcard}, nil
 }
 
+// Child returns the i-th child public key, which is hardened if the key has a
+// hardened wildcard.
 func (xpub *XPub) Child(i uint32) (Key, error) {
-	child, err := xpub.key.Derive(i)
+	if i >= HardenedKeyStart {
+		return nil, fmt.Errorf("invalid child index %d", i)
+	}
+
+	child, err := deriveKey(xpub.key, DerivationPath{xpub.wildcard.child(i)})
 	if err != nil {
 		return nil, err
 	}
@@ -179,6 +443,15 @@ func (xpub *XPub) Child(i uint32) (Key, error) {
 }
 
 func (xpub *XPub) String() string { return xpub.key.String() }
+
+// fingerprint returns the first 4 bytes of the key's hash160.
+func (xpub *XPub) fingerprint() [4]byte {
+	var fp [4]byte
+	if pub, err := xpub.key.ECPubKey(); err == nil {
+		copy(fp[:], Hash160(pub.SerializeCompressed()))
+	}
+	return fp
+}
 func (xpub *XPub) PubKey() (string, error) {
 	pub, err := xpub.key.ECPubKey()
 	if err != nil {
@@ -187,3 +460,22 @@ func (xpub *XPub) PubKey() (string, error) {
 
 	return hex.EncodeToString(pub.SerializeCompressed()), nil
 }
+
+// neuter returns the extended public key of an extended private key, whose
+// version bytes are the ones of the network using the private key version.
+func neuter(key *hdkeychain.ExtendedKey) (*hdkeychain.ExtendedKey, error) {
+	version, ok := hdPublicKeyID(key.Version())
+	if !ok || !key.IsPrivate() {
+		return key.Neuter()
+	}
+
+	pub, err := key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	parentFP := make([]byte, 4)
+	binary.BigEndian.PutUint32(parentFP, key.ParentFingerprint())
+	return hdkeychain.NewExtendedKey(version, pub.SerializeCompressed(), key.ChainCode(),
+		parentFP, key.Depth(), key.ChildIndex(), false), nil
+}
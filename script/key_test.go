@@ -1,6 +1,7 @@
 package script
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,3 +60,174 @@ func TestXPubDerivations(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyOrigins(t *testing.T) {
+	// BIP32 test vector 1
+	const (
+		master = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+		child  = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	)
+
+	testCases := []struct {
+		name           string
+		key            string
+		expectedKey    string
+		expectedOrigin string
+	}{
+		{
+			name:           "xpub with origin",
+			key:            "[3442193e/0']" + child + "/1",
+			expectedKey:    "03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c",
+			expectedOrigin: "[3442193e/0'/1]",
+		},
+		{
+			name:           "xpub without origin",
+			key:            master + "/0/1",
+			expectedOrigin: "[3442193e/0/1]",
+		},
+		{
+			name:           "pubkey with origin",
+			key:            "[d34db33f/44h/0h/0h]03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c",
+			expectedKey:    "03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c",
+			expectedOrigin: "[d34db33f/44'/0'/0']",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			key, origin, err := evalKey(test.key)
+			require.NoError(t, err)
+
+			if test.expectedKey != "" {
+				assert.Equal(t, test.expectedKey, key.String())
+			}
+			assert.Equal(t, test.expectedOrigin, origin.String())
+		})
+	}
+
+	_, origin, err := evalKey("03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c")
+	require.NoError(t, err)
+	assert.Nil(t, origin)
+
+	for _, key := range []string{
+		"[3442193]" + child,
+		"[zzzzzzzz/0]" + child,
+		"[3442193e/x]" + child,
+		"[3442193e/0" + child,
+	} {
+		_, _, err := evalKey(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestScriptKeys(t *testing.T) {
+	script, err := ParseWithPath(`sh(wsh(multi(2,
+		[d34db33f/48'/0'/0'/2']xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw/0/*,
+		03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c
+	)))`, "m/7")
+	require.NoError(t, err)
+
+	keys := script.Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, "[d34db33f/48'/0'/0'/2'/0/7]", keys[0].Origin.String())
+	assert.Nil(t, keys[1].Origin)
+	assert.Equal(t, "03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c", hex.EncodeToString(keys[1].PubKey))
+
+	script, err = Parse("tr([d34db33f/86'/0'/0']03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c,pk([aabbccdd/1]0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798))")
+	require.NoError(t, err)
+
+	keys = script.Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, "501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c", hex.EncodeToString(keys[0].PubKey))
+	assert.Equal(t, "[d34db33f/86'/0'/0']", keys[0].Origin.String())
+	assert.Equal(t, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", hex.EncodeToString(keys[1].PubKey))
+	assert.Equal(t, "[aabbccdd/1]", keys[1].Origin.String())
+}
+
+func TestPrivateKeys(t *testing.T) {
+	// BIP32 test vector 1.
+	const (
+		xprv    = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+		xpub    = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+		xpub0h  = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+		wif     = "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+		wifU    = "5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf"
+		pubKey  = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		pubKeyU = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	)
+
+	t.Run("WIF", func(t *testing.T) {
+		for _, test := range []struct{ wif, pubKey string }{
+			{wif, pubKey},
+			{wifU, pubKeyU},
+		} {
+			priv, err := NewPrivKey(test.wif)
+			require.NoError(t, err)
+			assert.Equal(t, test.wif, priv.String())
+			assert.Equal(t, test.pubKey, priv.PubKey().String())
+
+			pub, _, err := evalKey(test.wif)
+			require.NoError(t, err)
+			assert.Equal(t, test.pubKey, pub.String())
+		}
+
+		_, err := NewPrivKey(pubKey)
+		assert.Error(t, err)
+	})
+
+	t.Run("hardened derivation", func(t *testing.T) {
+		priv, err := Parse("pkh(" + xprv + "/0'/*')")
+		require.NoError(t, err)
+		pub, err := ParseWithPath("pkh("+xprv+"/0'/*')", "m/1")
+		require.NoError(t, err)
+		assert.NotEqual(t, priv.Bytes(), pub.Bytes())
+
+		expected, _, err := evalKey(xprv + "/0'/1'")
+		require.NoError(t, err)
+		assert.Equal(t, expected.Bytes(), pub.Keys()[0].PubKey)
+		assert.Equal(t, "[3442193e/0'/1']", pub.Keys()[0].Origin.String())
+
+		_, err = Parse("pkh(" + xpub + "/0'/*)")
+		assert.Error(t, err)
+	})
+
+	t.Run("public descriptor", func(t *testing.T) {
+		testCases := []struct {
+			desc     string
+			expected string
+		}{
+			{"pkh(" + wif + ")", "pkh(" + pubKey + ")"},
+			{"pk([deadbeef/1]" + wifU + ")", "pk([deadbeef/1]" + pubKeyU + ")"},
+			{"wpkh(" + xprv + "/1/*)", "wpkh(" + xpub + "/1/*)"},
+			{"wpkh(" + xprv + "/0'/1/*)", "wpkh([3442193e/0']" + xpub0h + "/1/*)"},
+			{"wpkh([deadbeef/44']" + xprv + "/0h/<0;1>/*)", "wpkh([deadbeef/44'/0']" + xpub0h + "/<0;1>/*)"},
+			{"wsh(multi(2," + xprv + "/0'," + pubKey + "))", "wsh(multi(2,[3442193e/0']" + xpub0h + "," + pubKey + "))"},
+			{"addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)", "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)"},
+		}
+
+		for _, test := range testCases {
+			desc, err := PublicDescriptor(test.desc)
+			require.NoError(t, err)
+			assert.Equal(t, withChecksum(test.expected), desc)
+
+			// Both descriptors must produce the same scripts.
+			if IsMultipath(desc) {
+				continue
+			}
+			priv, err := ParseWithPath(test.desc, "m/5")
+			require.NoError(t, err)
+			pub, err := ParseWithPath(desc, "m/5")
+			require.NoError(t, err)
+			assert.Equal(t, priv.Bytes(), pub.Bytes(), test.desc)
+			assert.Equal(t, priv.Keys(), pub.Keys(), test.desc)
+		}
+
+		for _, desc := range []string{
+			"wpkh(" + xprv + "/0/*')",
+			"wpkh(" + xprv + "/0'/1/2h)",
+		} {
+			_, err := PublicDescriptor(desc)
+			assert.Error(t, err, desc)
+		}
+	})
+}
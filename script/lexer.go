@@ -0,0 +1,121 @@
+package script
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// tokWord is any run of characters which are not delimiters, like
+	// operator names, numbers or keys.
+	tokWord
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of input"
+	case tokWord:
+		return "word"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokLBrace:
+		return "'{'"
+	case tokRBrace:
+		return "'}'"
+	case tokComma:
+		return "','"
+	}
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+type token struct {
+	kind tokenKind
+	val  string
+	// pos is the byte offset of the token in the input.
+	pos int
+}
+
+func (t token) String() string {
+	if t.kind == tokWord {
+		return fmt.Sprintf("'%s'", t.val)
+	}
+	return t.kind.String()
+}
+
+var delimiters = map[rune]tokenKind{
+	'(': tokLParen,
+	')': tokRParen,
+	'{': tokLBrace,
+	'}': tokRBrace,
+	',': tokComma,
+}
+
+// lexer splits a descriptor into tokens, whitespace is ignored.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// next returns the next token in the input, tokEOF once it has been consumed.
+func (l *lexer) next() token {
+	// Skip whitespace.
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+
+	if l.pos >= len(l.input) {
+		return token{kind: tokEOF, pos: l.pos}
+	}
+
+	start := l.pos
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	if kind, ok := delimiters[r]; ok {
+		l.pos += size
+		return token{kind: kind, val: string(r), pos: start}
+	}
+
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if _, ok := delimiters[r]; ok || unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+
+	return token{kind: tokWord, val: l.input[start:l.pos], pos: start}
+}
+
+// tokenize returns all the tokens of the input, including the final tokEOF.
+func tokenize(input string) []token {
+	var (
+		l      = newLexer(input)
+		tokens []token
+	)
+	for {
+		tok := l.next()
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens
+		}
+	}
+}
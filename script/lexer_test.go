@@ -0,0 +1,32 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer(t *testing.T) {
+	tokens := tokenize(" tr(K, {pk([d34db33f/86'/0h]xpub/0/*),\n\tpk(B)} )")
+
+	expected := []token{
+		{kind: tokWord, val: "tr", pos: 1},
+		{kind: tokLParen, val: "(", pos: 3},
+		{kind: tokWord, val: "K", pos: 4},
+		{kind: tokComma, val: ",", pos: 5},
+		{kind: tokLBrace, val: "{", pos: 7},
+		{kind: tokWord, val: "pk", pos: 8},
+		{kind: tokLParen, val: "(", pos: 10},
+		{kind: tokWord, val: "[d34db33f/86'/0h]xpub/0/*", pos: 11},
+		{kind: tokRParen, val: ")", pos: 36},
+		{kind: tokComma, val: ",", pos: 37},
+		{kind: tokWord, val: "pk", pos: 40},
+		{kind: tokLParen, val: "(", pos: 42},
+		{kind: tokWord, val: "B", pos: 43},
+		{kind: tokRParen, val: ")", pos: 44},
+		{kind: tokRBrace, val: "}", pos: 45},
+		{kind: tokRParen, val: ")", pos: 47},
+		{kind: tokEOF, pos: 48},
+	}
+	assert.Equal(t, expected, tokens)
+}
@@ -0,0 +1,1047 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Miniscript (BIP379) is a structured subset of Bitcoin Script which can be
+// type checked and composed. Expressions are parsed into a tree of fragments
+// which is type checked and compiled for the context it's used in: P2WSH
+// (inside wsh()) or tapscript (inside tr() leaves).
+
+type msContext int
+
+const (
+	msP2WSH msContext = iota
+	msTapscript
+)
+
+const (
+	// maxStandardP2WSHScriptSize is the maximum standard size of a P2WSH
+	// witness script.
+	maxStandardP2WSHScriptSize = 3600
+
+	// sequenceLockTimeTypeFlag marks relative timelocks as time based
+	// (BIP68), otherwise they are height based.
+	sequenceLockTimeTypeFlag = 1 << 22
+
+	// lockTimeThreshold is the value from which absolute timelocks are time
+	// based, below it they are height based.
+	lockTimeThreshold = 500000000
+)
+
+type msFragment int
+
+const (
+	msJust0 msFragment = iota
+	msJust1
+	msPkK
+	msPkH
+	msOlder
+	msAfter
+	msSha256
+	msHash256
+	msRipemd160
+	msHash160
+	msWrapA
+	msWrapS
+	msWrapC
+	msWrapD
+	msWrapV
+	msWrapJ
+	msWrapN
+	msAndV
+	msAndB
+	msOrB
+	msOrC
+	msOrD
+	msOrI
+	msAndOr
+	msThresh
+	msMulti
+	msMultiA
+)
+
+var msFragmentNames = map[msFragment]string{
+	msJust0:     "0",
+	msJust1:     "1",
+	msPkK:       "pk_k",
+	msPkH:       "pk_h",
+	msOlder:     "older",
+	msAfter:     "after",
+	msSha256:    "sha256",
+	msHash256:   "hash256",
+	msRipemd160: "ripemd160",
+	msHash160:   "hash160",
+	msAndV:      "and_v",
+	msAndB:      "and_b",
+	msOrB:       "or_b",
+	msOrC:       "or_c",
+	msOrD:       "or_d",
+	msOrI:       "or_i",
+	msAndOr:     "andor",
+	msThresh:    "thresh",
+	msMulti:     "multi",
+	msMultiA:    "multi_a",
+}
+
+// msWrappers are the `X:` wrappers which map to their own fragment, the t:,
+// l: and u: wrappers are aliases of and_v() and or_i().
+var msWrappers = map[byte]msFragment{
+	'a': msWrapA,
+	's': msWrapS,
+	'c': msWrapC,
+	'd': msWrapD,
+	'v': msWrapV,
+	'j': msWrapJ,
+	'n': msWrapN,
+}
+
+// msNode is a miniscript fragment.
+type msNode struct {
+	frag msFragment
+	// k is the threshold of thresh() and multi() or the timelock of older()
+	// and after().
+	k    uint32
+	keys []string
+	hash []byte
+	subs []*msNode
+}
+
+// msType is a set of miniscript type properties: the basic types B, V, K and
+// W, the properties z, o, n, d, u, e, f, s, m and x, and the g, h, i, j and k
+// timelock properties.
+type msType uint32
+
+const msTypeChars = "BVKWzondufesmxghijk"
+
+func mst(props string) msType {
+	var t msType
+	for i := 0; i < len(props); i++ {
+		t |= 1 << uint(strings.IndexByte(msTypeChars, props[i]))
+	}
+	return t
+}
+
+// has returns if t has all the given properties.
+func (t msType) has(props string) bool {
+	p := mst(props)
+	return t&p == p
+}
+
+// onlyIf returns t if cond holds, or no properties otherwise.
+func (t msType) onlyIf(cond bool) msType {
+	if cond {
+		return t
+	}
+	return 0
+}
+
+func (t msType) String() string {
+	var sb strings.Builder
+	for i := 0; i < len(msTypeChars); i++ {
+		if t&(1<<uint(i)) != 0 {
+			sb.WriteByte(msTypeChars[i])
+		}
+	}
+	return sb.String()
+}
+
+// mixesTimelocks returns if x and y use timelocks of different kinds (height
+// and time based) which can't be satisfied together.
+func mixesTimelocks(x, y msType) bool {
+	return x.has("g") && y.has("h") || x.has("h") && y.has("g") ||
+		x.has("i") && y.has("j") || x.has("j") && y.has("i")
+}
+
+// computeType returns the type of the fragment given the type of its
+// subexpressions, following the BIP379 type system. Fragments with invalid
+// subexpressions end up with no basic type.
+func (n *msNode) computeType(ctx msContext, subs []msType) msType {
+	var x, y, z msType
+	switch len(subs) {
+	case 3:
+		z = subs[2]
+		fallthrough
+	case 2:
+		y = subs[1]
+		fallthrough
+	case 1:
+		x = subs[0]
+	}
+
+	switch n.frag {
+	case msJust0:
+		return mst("Bzudemsxk")
+	case msJust1:
+		return mst("Bzufmxk")
+	case msPkK:
+		return mst("Konudemsxk")
+	case msPkH:
+		return mst("Knudemsxk")
+	case msOlder:
+		return mst("Bzfmxk") |
+			mst("g").onlyIf(n.k&sequenceLockTimeTypeFlag != 0) |
+			mst("h").onlyIf(n.k&sequenceLockTimeTypeFlag == 0)
+	case msAfter:
+		return mst("Bzfmxk") |
+			mst("i").onlyIf(n.k >= lockTimeThreshold) |
+			mst("j").onlyIf(n.k < lockTimeThreshold)
+	case msSha256, msHash256, msRipemd160, msHash160:
+		return mst("Bonudmk")
+	case msWrapA:
+		return mst("W").onlyIf(x.has("B")) |
+			x&mst("ghijk") |
+			x&mst("udfems") |
+			mst("x")
+	case msWrapS:
+		return mst("W").onlyIf(x.has("Bo")) |
+			x&mst("ghijk") |
+			x&mst("udfemsx")
+	case msWrapC:
+		return mst("B").onlyIf(x.has("K")) |
+			x&mst("ghijk") |
+			x&mst("ondfem") |
+			mst("us")
+	case msWrapD:
+		// d: is only u in tapscript, where MINIMALIF is a consensus rule.
+		return mst("B").onlyIf(x.has("Vz")) |
+			mst("o").onlyIf(x.has("z")) |
+			mst("e").onlyIf(x.has("f")) |
+			x&mst("ghijk") |
+			x&mst("ms") |
+			mst("u").onlyIf(ctx == msTapscript) |
+			mst("ndx")
+	case msWrapV:
+		return mst("V").onlyIf(x.has("B")) |
+			x&mst("ghijk") |
+			x&mst("zonms") |
+			mst("fx")
+	case msWrapJ:
+		return mst("B").onlyIf(x.has("Bn")) |
+			mst("e").onlyIf(x.has("f")) |
+			x&mst("ghijk") |
+			x&mst("oums") |
+			mst("ndx")
+	case msWrapN:
+		return x&mst("ghijk") |
+			x&mst("Bzondfems") |
+			mst("ux")
+	case msAndV:
+		return (y & mst("KVB")).onlyIf(x.has("V")) |
+			x&mst("n") | (y & mst("n")).onlyIf(x.has("z")) |
+			((x | y) & mst("o")).onlyIf((x | y).has("z")) |
+			x&y&mst("dmz") |
+			(x|y)&mst("s") |
+			mst("f").onlyIf(y.has("f") || x.has("s")) |
+			y&mst("ux") |
+			(x|y)&mst("ghij") |
+			mst("k").onlyIf((x&y).has("k") && !mixesTimelocks(x, y))
+	case msAndB:
+		return (x & mst("B")).onlyIf(y.has("W")) |
+			((x | y) & mst("o")).onlyIf((x | y).has("z")) |
+			x&mst("n") | (y & mst("n")).onlyIf(x.has("z")) |
+			(x & y & mst("e")).onlyIf((x & y).has("s")) |
+			x&y&mst("dzm") |
+			mst("f").onlyIf((x&y).has("f") || x.has("sf") || y.has("sf")) |
+			(x|y)&mst("s") |
+			mst("ux") |
+			(x|y)&mst("ghij") |
+			mst("k").onlyIf((x&y).has("k") && !mixesTimelocks(x, y))
+	case msOrB:
+		return mst("B").onlyIf(x.has("Bd") && y.has("Wd")) |
+			((x | y) & mst("o")).onlyIf((x | y).has("z")) |
+			(x & y & mst("m")).onlyIf((x|y).has("s") && (x&y).has("e")) |
+			x&y&mst("zse") |
+			mst("dux") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case msOrD:
+		return (y & mst("B")).onlyIf(x.has("Bdu")) |
+			(x & mst("o")).onlyIf(y.has("z")) |
+			(x & y & mst("m")).onlyIf(x.has("e") && (x|y).has("s")) |
+			x&y&mst("zes") |
+			y&mst("ufdx") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case msOrC:
+		return (y & mst("V")).onlyIf(x.has("Bdu")) |
+			(x & mst("o")).onlyIf(y.has("z")) |
+			(x & y & mst("m")).onlyIf(x.has("e") && (x|y).has("s")) |
+			x&y&mst("zs") |
+			mst("fx") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case msOrI:
+		return x&y&mst("VBKufs") |
+			mst("o").onlyIf((x & y).has("z")) |
+			((x | y) & mst("e")).onlyIf((x | y).has("f")) |
+			(x & y & mst("m")).onlyIf((x | y).has("s")) |
+			(x|y)&mst("d") |
+			mst("x") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case msAndOr:
+		return (y & z & mst("BKV")).onlyIf(x.has("Bdu")) |
+			x&y&z&mst("z") |
+			((x | (y & z)) & mst("o")).onlyIf((x | (y & z)).has("z")) |
+			y&z&mst("u") |
+			(z & mst("f")).onlyIf(x.has("s") || y.has("f")) |
+			z&mst("d") |
+			(z & mst("e")).onlyIf(x.has("s") || y.has("f")) |
+			(x & y & z & mst("m")).onlyIf(x.has("e") && (x|y|z).has("s")) |
+			z&(x|y)&mst("s") |
+			mst("x") |
+			(x|y|z)&mst("ghij") |
+			mst("k").onlyIf((x&y&z).has("k") && !mixesTimelocks(x, y))
+	case msMulti:
+		return mst("Bnudemsk")
+	case msMultiA:
+		return mst("Budemsk")
+	case msThresh:
+		var (
+			allE, allM = true, true
+			args, numS int
+			timelocks  = mst("k")
+		)
+		for i, t := range subs {
+			// The first subexpression must be Bdu and the rest Wdu.
+			if i == 0 && !t.has("Bdu") || i > 0 && !t.has("Wdu") {
+				return 0
+			}
+			allE = allE && t.has("e")
+			allM = allM && t.has("m")
+			if t.has("s") {
+				numS++
+			}
+			switch {
+			case t.has("z"):
+			case t.has("o"):
+				args++
+			default:
+				args += 2
+			}
+
+			// Mixing timelocks only matters if more than one subexpression
+			// has to be satisfied.
+			mixes := n.k > 1 && mixesTimelocks(timelocks, t)
+			timelocks = (timelocks|t)&mst("ghij") |
+				mst("k").onlyIf((timelocks&t).has("k") && !mixes)
+		}
+
+		k, total := int(n.k), len(subs)
+		return mst("Bdu") |
+			mst("z").onlyIf(args == 0) |
+			mst("o").onlyIf(args == 1) |
+			mst("e").onlyIf(allE && numS == total) |
+			mst("m").onlyIf(allE && allM && numS >= total-k) |
+			mst("s").onlyIf(numS >= total-k+1) |
+			timelocks
+	}
+	return 0
+}
+
+// typ type checks the fragment and its subexpressions in ctx.
+func (n *msNode) typ(ctx msContext) (msType, error) {
+	switch {
+	case n.frag == msMulti && ctx == msTapscript:
+		return 0, fmt.Errorf("miniscript: multi() is not allowed in tapscript, use multi_a()")
+	case n.frag == msMultiA && ctx == msP2WSH:
+		return 0, fmt.Errorf("miniscript: multi_a() is only allowed in tapscript")
+	}
+
+	subs := make([]msType, len(n.subs))
+	for i, sub := range n.subs {
+		t, err := sub.typ(ctx)
+		if err != nil {
+			return 0, err
+		}
+		subs[i] = t
+	}
+
+	t := n.computeType(ctx, subs)
+
+	// Valid expressions have exactly one basic type.
+	basic := 0
+	for _, b := range "BVKW" {
+		if t.has(string(b)) {
+			basic++
+		}
+	}
+	if basic != 1 {
+		return 0, fmt.Errorf("miniscript: '%s' has an invalid type", n)
+	}
+
+	return t, nil
+}
+
+// pushKey returns the push of the serialized key for ctx, appending it to
+// keys.
+func pushKey(ctx msContext, s string, keys *[]DerivedKey) ([]byte, error) {
+	key, origin, err := evalKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := key.Bytes()
+	if ctx == msTapscript {
+		if keyBytes, err = xOnly(keyBytes); err != nil {
+			return nil, err
+		}
+	} else if !key.IsCompressed() {
+		return nil, fmt.Errorf("miniscript: key '%s' must be compressed", s)
+	}
+
+	*keys = append(*keys, DerivedKey{PubKey: keyBytes, Origin: origin})
+	return NewBytes([]byte{OP_PUSH_BYTES(len(keyBytes))}, keyBytes), nil
+}
+
+// msOps counts the non push opcodes of a fragment: count are the ones in its
+// script and sat and dsat the ones additionally executed when satisfying and
+// dissatisfying it, which are the keys of OP_CHECKMULTISIG. sat and dsat are
+// -1 when there's no such satisfaction.
+type msOps struct {
+	count, sat, dsat int
+}
+
+// opsSum returns the executed opcodes of two satisfactions done together.
+func opsSum(a, b int) int {
+	if a < 0 || b < 0 {
+		return -1
+	}
+	return a + b
+}
+
+// opsMax returns the executed opcodes of the costliest satisfaction.
+func opsMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ops returns the opcodes of the fragment in ctx, as counted by Bitcoin
+// Core. The fragment must be type checked.
+func (n *msNode) ops(ctx msContext) msOps {
+	subs := make([]msOps, len(n.subs))
+	count := 0
+	for i, sub := range n.subs {
+		subs[i] = sub.ops(ctx)
+		count += subs[i].count
+	}
+
+	var x, y, z msOps
+	switch len(subs) {
+	case 3:
+		z = subs[2]
+		fallthrough
+	case 2:
+		y = subs[1]
+		fallthrough
+	case 1:
+		x = subs[0]
+	}
+
+	switch n.frag {
+	case msJust0:
+		return msOps{0, -1, 0}
+	case msJust1:
+		return msOps{0, 0, -1}
+	case msPkK:
+		return msOps{0, 0, 0}
+	case msPkH:
+		return msOps{3, 0, 0}
+	case msOlder, msAfter:
+		return msOps{1, 0, -1}
+	case msSha256, msHash256, msRipemd160, msHash160:
+		return msOps{4, 0, -1}
+	case msWrapA:
+		return msOps{count + 2, x.sat, x.dsat}
+	case msWrapS, msWrapC, msWrapN:
+		return msOps{count + 1, x.sat, x.dsat}
+	case msWrapD:
+		return msOps{count + 3, x.sat, 0}
+	case msWrapV:
+		// OP_VERIFY is only added for expressions without a VERIFY version.
+		if t, _ := n.subs[0].typ(ctx); t.has("x") {
+			count++
+		}
+		return msOps{count, x.sat, -1}
+	case msWrapJ:
+		return msOps{count + 4, x.sat, 0}
+	case msAndV:
+		return msOps{count, opsSum(x.sat, y.sat), -1}
+	case msAndB:
+		return msOps{count + 1, opsSum(x.sat, y.sat), opsSum(x.dsat, y.dsat)}
+	case msOrB:
+		return msOps{count + 1, opsMax(opsSum(x.sat, y.dsat), opsSum(x.dsat, y.sat)), opsSum(x.dsat, y.dsat)}
+	case msOrC:
+		return msOps{count + 2, opsMax(x.sat, opsSum(x.dsat, y.sat)), -1}
+	case msOrD:
+		return msOps{count + 3, opsMax(x.sat, opsSum(x.dsat, y.sat)), opsSum(x.dsat, y.dsat)}
+	case msOrI:
+		return msOps{count + 3, opsMax(x.sat, y.sat), opsMax(x.dsat, y.dsat)}
+	case msAndOr:
+		return msOps{count + 3, opsMax(opsSum(x.sat, y.sat), opsSum(x.dsat, z.sat)), opsSum(x.dsat, z.dsat)}
+	case msThresh:
+		// sats[i] are the opcodes executed satisfying i subexpressions.
+		sats := []int{0}
+		for _, sub := range subs {
+			next := make([]int, len(sats)+1)
+			next[0] = opsSum(sats[0], sub.dsat)
+			for i := 1; i < len(sats); i++ {
+				next[i] = opsMax(opsSum(sats[i], sub.dsat), opsSum(sats[i-1], sub.sat))
+			}
+			next[len(sats)] = opsSum(sats[len(sats)-1], sub.sat)
+			sats = next
+		}
+		// An OP_ADD per subexpression but the first, plus OP_EQUAL.
+		return msOps{count + len(subs), sats[n.k], sats[0]}
+	case msMulti:
+		return msOps{1, len(n.keys), len(n.keys)}
+	case msMultiA:
+		return msOps{len(n.keys) + 1, 0, 0}
+	}
+
+	return msOps{count, -1, -1}
+}
+
+// compile returns the script of the fragment in ctx, appending the keys used
+// by it.
+func (n *msNode) compile(ctx msContext, keys *[]DerivedKey) ([]byte, error) {
+	subs := make([][]byte, len(n.subs))
+	for i, sub := range n.subs {
+		script, err := sub.compile(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = script
+	}
+
+	switch n.frag {
+	case msJust0:
+		return []byte{OP_0}, nil
+	case msJust1:
+		return []byte{OP_1}, nil
+	case msPkK:
+		return pushKey(ctx, n.keys[0], keys)
+	case msPkH:
+		key, err := pushKey(ctx, n.keys[0], keys)
+		if err != nil {
+			return nil, err
+		}
+		return NewBytes(
+			[]byte{OP_DUP, OP_HASH160, OP_PUSH_BYTES(20)},
+			Hash160(key[1:]),
+			[]byte{OP_EQUALVERIFY},
+		), nil
+	case msOlder:
+		return NewBytes(pushNumber(int64(n.k)), []byte{OP_CHECKSEQUENCEVERIFY}), nil
+	case msAfter:
+		return NewBytes(pushNumber(int64(n.k)), []byte{OP_CHECKLOCKTIMEVERIFY}), nil
+	case msSha256, msHash256, msRipemd160, msHash160:
+		op := map[msFragment]byte{
+			msSha256:    OP_SHA256,
+			msHash256:   OP_HASH256,
+			msRipemd160: OP_RIPEMD160,
+			msHash160:   OP_HASH160,
+		}[n.frag]
+		return NewBytes(
+			[]byte{OP_SIZE}, pushNumber(32), []byte{OP_EQUALVERIFY, op},
+			[]byte{OP_PUSH_BYTES(len(n.hash))}, n.hash,
+			[]byte{OP_EQUAL},
+		), nil
+	case msWrapA:
+		return NewBytes([]byte{OP_TOALTSTACK}, subs[0], []byte{OP_FROMALTSTACK}), nil
+	case msWrapS:
+		return NewBytes([]byte{OP_SWAP}, subs[0]), nil
+	case msWrapC:
+		return NewBytes(subs[0], []byte{OP_CHECKSIG}), nil
+	case msWrapD:
+		return NewBytes([]byte{OP_DUP, OP_IF}, subs[0], []byte{OP_ENDIF}), nil
+	case msWrapV:
+		t, err := n.subs[0].typ(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if t.has("x") {
+			return NewBytes(subs[0], []byte{OP_VERIFY}), nil
+		}
+
+		// Expressions without the x property end in OP_EQUAL, OP_CHECKSIG,
+		// OP_CHECKMULTISIG or OP_NUMEQUAL, which are replaced by their
+		// VERIFY version, the next opcode.
+		script := NewBytes(subs[0])
+		script[len(script)-1]++
+		return script, nil
+	case msWrapJ:
+		return NewBytes([]byte{OP_SIZE, OP_0NOTEQUAL, OP_IF}, subs[0], []byte{OP_ENDIF}), nil
+	case msWrapN:
+		return NewBytes(subs[0], []byte{OP_0NOTEQUAL}), nil
+	case msAndV:
+		return NewBytes(subs[0], subs[1]), nil
+	case msAndB:
+		return NewBytes(subs[0], subs[1], []byte{OP_BOOLAND}), nil
+	case msOrB:
+		return NewBytes(subs[0], subs[1], []byte{OP_BOOLOR}), nil
+	case msOrC:
+		return NewBytes(subs[0], []byte{OP_NOTIF}, subs[1], []byte{OP_ENDIF}), nil
+	case msOrD:
+		return NewBytes(subs[0], []byte{OP_IFDUP, OP_NOTIF}, subs[1], []byte{OP_ENDIF}), nil
+	case msOrI:
+		return NewBytes([]byte{OP_IF}, subs[0], []byte{OP_ELSE}, subs[1], []byte{OP_ENDIF}), nil
+	case msAndOr:
+		return NewBytes(subs[0], []byte{OP_NOTIF}, subs[2], []byte{OP_ELSE}, subs[1], []byte{OP_ENDIF}), nil
+	case msThresh:
+		script := subs[0]
+		for _, sub := range subs[1:] {
+			script = NewBytes(script, sub, []byte{OP_ADD})
+		}
+		return NewBytes(script, pushNumber(int64(n.k)), []byte{OP_EQUAL}), nil
+	case msMulti:
+		script := pushNumber(int64(n.k))
+		for _, key := range n.keys {
+			push, err := pushKey(ctx, key, keys)
+			if err != nil {
+				return nil, err
+			}
+			script = NewBytes(script, push)
+		}
+		return NewBytes(script, pushNumber(int64(len(n.keys))), []byte{OP_CHECKMULTISIG}), nil
+	case msMultiA:
+		var script []byte
+		for i, key := range n.keys {
+			push, err := pushKey(ctx, key, keys)
+			if err != nil {
+				return nil, err
+			}
+			op := byte(OP_CHECKSIGADD)
+			if i == 0 {
+				op = OP_CHECKSIG
+			}
+			script = NewBytes(script, push, []byte{op})
+		}
+		return NewBytes(script, pushNumber(int64(n.k)), []byte{OP_NUMEQUAL}), nil
+	}
+
+	return nil, fmt.Errorf("miniscript: unknown fragment %d", n.frag)
+}
+
+// wrapper returns the wrapper letter and the wrapped expression if n is a
+// wrapper, including the t:, l: and u: aliases.
+func (n *msNode) wrapper() (byte, *msNode) {
+	switch n.frag {
+	case msWrapA, msWrapS, msWrapD, msWrapV, msWrapJ, msWrapN:
+		for w, frag := range msWrappers {
+			if frag == n.frag {
+				return w, n.subs[0]
+			}
+		}
+	case msWrapC:
+		// c:pk_k() and c:pk_h() are written as pk() and pkh().
+		if sub := n.subs[0].frag; sub != msPkK && sub != msPkH {
+			return 'c', n.subs[0]
+		}
+	case msAndV:
+		if n.subs[1].frag == msJust1 {
+			return 't', n.subs[0]
+		}
+	case msOrI:
+		if n.subs[0].frag == msJust0 {
+			return 'l', n.subs[1]
+		}
+		if n.subs[1].frag == msJust0 {
+			return 'u', n.subs[0]
+		}
+	}
+	return 0, nil
+}
+
+// String returns the miniscript expression using the shortest aliases.
+func (n *msNode) String() string {
+	var wrappers []byte
+	for {
+		w, sub := n.wrapper()
+		if w == 0 {
+			break
+		}
+		wrappers = append(wrappers, w)
+		n = sub
+	}
+
+	var args []string
+	name := msFragmentNames[n.frag]
+	switch n.frag {
+	case msJust0, msJust1:
+	case msWrapC:
+		name = "pk"
+		if n.subs[0].frag == msPkH {
+			name = "pkh"
+		}
+		args = n.subs[0].keys
+	case msPkK, msPkH:
+		args = n.keys
+	case msOlder, msAfter:
+		args = []string{strconv.FormatUint(uint64(n.k), 10)}
+	case msSha256, msHash256, msRipemd160, msHash160:
+		args = []string{hex.EncodeToString(n.hash)}
+	case msMulti, msMultiA:
+		args = append([]string{strconv.FormatUint(uint64(n.k), 10)}, n.keys...)
+	default:
+		subs := n.subs
+		if n.frag == msAndOr && subs[2].frag == msJust0 {
+			name, subs = "and_n", subs[:2]
+		}
+		if n.frag == msThresh {
+			args = []string{strconv.FormatUint(uint64(n.k), 10)}
+		}
+		for _, sub := range subs {
+			args = append(args, sub.String())
+		}
+	}
+
+	s := name
+	if n.frag != msJust0 && n.frag != msJust1 {
+		s += "(" + strings.Join(args, ",") + ")"
+	}
+	if len(wrappers) > 0 {
+		s = string(wrappers) + ":" + s
+	}
+	return s
+}
+
+type miniscript struct {
+	root *msNode
+}
+
+// ParseMiniscript parses a miniscript expression such as
+// `and_v(v:pk(KEY),older(144))`, which can be used as the script of Wsh() or
+// as a Taproot Leaf(). The expression is type checked once evaluated, as the
+// result depends on the context it's used in.
+func ParseMiniscript(s string) (ScriptExpr, error) {
+	desc, _, err := splitChecksum(s)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := parseDescriptor(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseMiniscript(n, "")
+	if err != nil {
+		return nil, err
+	}
+	return &miniscript{root: root}, nil
+}
+
+func (m *miniscript) String() string {
+	return withChecksum(m.root.String())
+}
+
+// Eval returns the P2WSH witness script of the miniscript, which has no
+// address on its own.
+func (m *miniscript) Eval() (*Script, error) {
+	return m.eval(msP2WSH)
+}
+
+func (m *miniscript) evalTapscript() (*Script, error) {
+	return m.eval(msTapscript)
+}
+
+func (m *miniscript) eval(ctx msContext) (*Script, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+
+	var keys []DerivedKey
+	script, err := m.root.compile(ctx, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkDuplicateKeys(keys); err != nil {
+		return nil, err
+	}
+
+	if ctx == msP2WSH && len(script) > maxStandardP2WSHScriptSize {
+		return nil, fmt.Errorf("miniscript: script is %d bytes, larger than the %d bytes limit", len(script), maxStandardP2WSHScriptSize)
+	}
+
+	eval := &Script{bytes: script, keys: keys}
+	if sat, _ := m.root.satisfaction(ctx); sat.ok {
+		eval.stack = sat.items
+	}
+	return eval, nil
+}
+
+// check verifies that the miniscript is sane in ctx: it must be a B
+// expression which can't be malleated, requires a signature, doesn't mix
+// height and time based timelocks and, in P2WSH, doesn't exceed the opcodes
+// limit.
+func (m *miniscript) check(ctx msContext) error {
+	t, err := m.root.typ(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !t.has("B"):
+		return fmt.Errorf("miniscript: top level expression must be of type B, got %s", t)
+	case !t.has("m"):
+		return fmt.Errorf("miniscript: '%s' is malleable", m.root)
+	case !t.has("s"):
+		return fmt.Errorf("miniscript: '%s' can be spent without a signature", m.root)
+	case !t.has("k"):
+		return fmt.Errorf("miniscript: '%s' mixes height and time based timelocks", m.root)
+	}
+
+	if ops := m.root.ops(ctx); ctx == msP2WSH && ops.sat >= 0 && ops.count+ops.sat > maxOpsPerScript {
+		return fmt.Errorf("miniscript: script executes %d opcodes, more than the %d opcodes limit", ops.count+ops.sat, maxOpsPerScript)
+	}
+	return nil
+}
+
+// checkDuplicateKeys verifies that no key is used twice, as signatures for
+// one of them would satisfy the other as well.
+func checkDuplicateKeys(keys []DerivedKey) error {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[string(key.PubKey)] {
+			return fmt.Errorf("miniscript: duplicate key '%x'", key.PubKey)
+		}
+		seen[string(key.PubKey)] = true
+	}
+	return nil
+}
+
+// isMiniscript returns if an expression named name in wsh() or tr() has to be
+// parsed as miniscript. Expressions that are also descriptors, like pk() or
+// multi(), are parsed as descriptors as both produce the same script.
+func isMiniscript(name string) bool {
+	if strings.Contains(name, ":") {
+		return true
+	}
+
+	switch name {
+	case "pk", "pkh", "multi", "multi_a":
+		return false
+	case "and_n":
+		return true
+	}
+	for _, frag := range msFragmentNames {
+		if frag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compileMiniscript parses and type checks a miniscript expression.
+func compileMiniscript(n *node, path string, ctx parseCtx) (ScriptExpr, error) {
+	root, err := parseMiniscript(n, path)
+	if err != nil {
+		return nil, err
+	}
+
+	msCtx := msP2WSH
+	if ctx == ctxTap {
+		msCtx = msTapscript
+	}
+
+	m := &miniscript{root: root}
+	if err := m.check(msCtx); err != nil {
+		return nil, errorAt(n.pos, "%v", err)
+	}
+	return m, nil
+}
+
+// parseMiniscript converts a syntax tree node into a miniscript fragment,
+// applying its wrappers.
+func parseMiniscript(n *node, path string) (*msNode, error) {
+	name, wrappers := n.name, ""
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		wrappers, name = name[:i], name[i+1:]
+		if wrappers == "" {
+			return nil, errorAt(n.pos, "missing miniscript wrappers in '%s'", n.name)
+		}
+	}
+
+	var (
+		ms  *msNode
+		err error
+	)
+	switch {
+	case n.kind == nodeWord && name == "0":
+		ms = &msNode{frag: msJust0}
+	case n.kind == nodeWord && name == "1":
+		ms = &msNode{frag: msJust1}
+	case n.kind == nodeCall:
+		ms, err = parseMiniscriptFragment(n, name, path)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errorAt(n.pos, "expected miniscript expression")
+	}
+
+	// Wrappers are applied from the innermost one, e.g. `vc:` is `v:c:`.
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		switch w := wrappers[i]; w {
+		case 't':
+			ms = &msNode{frag: msAndV, subs: []*msNode{ms, {frag: msJust1}}}
+		case 'l':
+			ms = &msNode{frag: msOrI, subs: []*msNode{{frag: msJust0}, ms}}
+		case 'u':
+			ms = &msNode{frag: msOrI, subs: []*msNode{ms, {frag: msJust0}}}
+		default:
+			frag, ok := msWrappers[w]
+			if !ok {
+				return nil, errorAt(n.pos, "invalid miniscript wrapper '%c'", w)
+			}
+			ms = &msNode{frag: frag, subs: []*msNode{ms}}
+		}
+	}
+
+	return ms, nil
+}
+
+func parseMiniscriptFragment(n *node, name, path string) (*msNode, error) {
+	switch name {
+	case "pk", "pkh", "pk_k", "pk_h":
+		if err := checkArgs(n, 1, 1); err != nil {
+			return nil, err
+		}
+
+		key, err := keyArg(n.args[0], path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "pk":
+			return &msNode{frag: msWrapC, subs: []*msNode{{frag: msPkK, keys: []string{key}}}}, nil
+		case "pkh":
+			return &msNode{frag: msWrapC, subs: []*msNode{{frag: msPkH, keys: []string{key}}}}, nil
+		case "pk_k":
+			return &msNode{frag: msPkK, keys: []string{key}}, nil
+		}
+		return &msNode{frag: msPkH, keys: []string{key}}, nil
+	case "older", "after":
+		if err := checkArgs(n, 1, 1); err != nil {
+			return nil, err
+		}
+
+		arg, err := wordArg(n.args[0], "timelock")
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := strconv.ParseUint(arg, 10, 31)
+		if err != nil || k == 0 {
+			return nil, errorAt(n.args[0].pos, "invalid %s() value '%s'", name, arg)
+		}
+
+		frag := msOlder
+		if name == "after" {
+			frag = msAfter
+		}
+		return &msNode{frag: frag, k: uint32(k)}, nil
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if err := checkArgs(n, 1, 1); err != nil {
+			return nil, err
+		}
+
+		arg, err := wordArg(n.args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+
+		size, frag := 32, map[string]msFragment{
+			"sha256":    msSha256,
+			"hash256":   msHash256,
+			"ripemd160": msRipemd160,
+			"hash160":   msHash160,
+		}[name]
+		if frag == msRipemd160 || frag == msHash160 {
+			size = 20
+		}
+
+		hash, err := hex.DecodeString(arg)
+		if err != nil || len(hash) != size {
+			return nil, errorAt(n.args[0].pos, "invalid %s() hash '%s', expected %d hex encoded bytes", name, arg, size)
+		}
+		return &msNode{frag: frag, hash: hash}, nil
+	case "and_v", "and_b", "and_n", "or_b", "or_c", "or_d", "or_i", "andor":
+		args := 2
+		if name == "andor" {
+			args = 3
+		}
+		if err := checkArgs(n, args, args); err != nil {
+			return nil, err
+		}
+
+		subs, err := parseMiniscriptSubs(n.args, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "and_n" {
+			return &msNode{frag: msAndOr, subs: append(subs, &msNode{frag: msJust0})}, nil
+		}
+		for frag, fragName := range msFragmentNames {
+			if fragName == name {
+				return &msNode{frag: frag, subs: subs}, nil
+			}
+		}
+	case "thresh":
+		if len(n.args) < 2 {
+			return nil, errorAt(n.pos, "thresh() requires a threshold and at least one expression")
+		}
+
+		arg, err := wordArg(n.args[0], "threshold")
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil || k < 1 || int(k) > len(n.args)-1 {
+			return nil, errorAt(n.args[0].pos, "invalid threshold '%s'", arg)
+		}
+
+		subs, err := parseMiniscriptSubs(n.args[1:], path)
+		if err != nil {
+			return nil, err
+		}
+		return &msNode{frag: msThresh, k: uint32(k), subs: subs}, nil
+	case "multi", "multi_a":
+		frag, max := msMulti, maxMultisigKeys
+		if name == "multi_a" {
+			frag, max = msMultiA, maxMultiAKeys
+		}
+
+		k, keys, err := compileMulti(n, path, max)
+		if err != nil {
+			return nil, err
+		}
+		return &msNode{frag: frag, k: uint32(k), keys: keys}, nil
+	}
+
+	return nil, errorAt(n.pos, "invalid miniscript fragment '%s'", name)
+}
+
+func parseMiniscriptSubs(args []*node, path string) ([]*msNode, error) {
+	subs := make([]*msNode, len(args))
+	for i, arg := range args {
+		sub, err := parseMiniscript(arg, path)
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = sub
+	}
+	return subs, nil
+}
@@ -0,0 +1,249 @@
+package script
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiniscript(t *testing.T) {
+	const (
+		keyA = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	)
+	r := strings.NewReplacer("A", keyA, "B", keyB)
+
+	testCases := []struct {
+		ms       string
+		expected string
+		// str is the expected canonical form, if different from ms.
+		str string
+	}{
+		{
+			ms:       "and_v(v:pk(A),older(144))",
+			expected: "21A" + "ad" + "029000b2",
+		},
+		{
+			ms:       "or_d(pk(A),and_v(v:pk(B),older(1000)))",
+			expected: "21Aac7364" + "21Bad" + "02e803b268",
+		},
+		{
+			ms:       "thresh(2,pk(A),s:pk(B),sln:older(10))",
+			expected: "21Aac" + "7c21Bac93" + "7c630067" + "5ab292" + "6893" + "5287",
+		},
+		{
+			ms:       "andor(pk(A),older(10),pk(B))",
+			expected: "21Aac64" + "21Bac67" + "5ab268",
+		},
+		{
+			ms:       "and_v(v:pkh(A),after(500000001))",
+			expected: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ad" + "040165cd1db1",
+		},
+		{
+			ms:       "and_v(vc:pk_k(A),older(5))",
+			expected: "21Aad55b2",
+			str:      "and_v(v:pk(A),older(5))",
+		},
+		{
+			ms:       "andor(pk(A),l:older(5),0)",
+			expected: "21Aac64" + "0067" + "63006755b268" + "68",
+			str:      "and_n(pk(A),l:older(5))",
+		},
+		{
+			ms:       "or_b(pk(A),s:pk(B))",
+			expected: "21Aac7c21Bac9b",
+		},
+		{
+			ms:       "and_v(v:pk(A),sha256(" + strings.Repeat("01", 32) + "))",
+			expected: "21Aad82012088a820" + strings.Repeat("01", 32) + "87",
+		},
+	}
+
+	for _, test := range testCases {
+		ms := r.Replace(test.ms)
+		t.Run(test.ms, func(t *testing.T) {
+			expr, err := ParseMiniscript(ms)
+			require.NoError(t, err)
+
+			eval, err := expr.Eval()
+			require.NoError(t, err)
+			assert.Equal(t, r.Replace(test.expected), hex.EncodeToString(eval.Bytes()))
+
+			str := ms
+			if test.str != "" {
+				str = r.Replace(test.str)
+			}
+			assert.Equal(t, withChecksum(str), expr.String())
+
+			// The same miniscript as a wsh() descriptor.
+			script, err := Parse("wsh(" + ms + ")")
+			require.NoError(t, err)
+			wsh, err := Wsh(expr).Eval()
+			require.NoError(t, err)
+			assert.Equal(t, wsh.Bytes(), script.Bytes())
+			assert.Equal(t, Sha256(eval.Bytes()), script.Bytes()[2:])
+			assert.NotEmpty(t, script.Address(Mainnet))
+			assert.Equal(t, withChecksum("wsh("+str+")"), Wsh(expr).String())
+		})
+	}
+}
+
+func TestMiniscriptTapscript(t *testing.T) {
+	const (
+		internal = "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"
+		keyA     = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB     = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	)
+
+	testCases := []struct {
+		ms       string
+		expected string
+	}{
+		{
+			ms:       "and_v(v:pk(" + keyA + "),older(144))",
+			expected: "20" + keyA + "ad029000b2",
+		},
+		{
+			ms:       "and_v(v:multi_a(1," + keyA + "," + keyB + "),older(10))",
+			expected: "20" + keyA + "ac20" + keyB[2:] + "ba519d5ab2",
+		},
+		{
+			ms:       "or_d(pk(" + keyB + "),and_v(v:pkh(" + keyA + "),older(10)))",
+			expected: "20" + keyB[2:] + "ac7364" + "76a914" + hex.EncodeToString(Hash160(mustDecodeHex(keyA))) + "88ad5ab268",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.ms, func(t *testing.T) {
+			script, err := Parse("tr(" + internal + "," + test.ms + ")")
+			require.NoError(t, err)
+
+			leaves := script.Taproot().Leaves()
+			require.Len(t, leaves, 1)
+			assert.Equal(t, test.expected, hex.EncodeToString(leaves[0].Script))
+
+			expr, err := ParseMiniscript(test.ms)
+			require.NoError(t, err)
+			eval, err := Tr(internal, Leaf(expr)).Eval()
+			require.NoError(t, err)
+			assert.Equal(t, script.Bytes(), eval.Bytes())
+		})
+	}
+}
+
+func TestInvalidMiniscript(t *testing.T) {
+	const (
+		keyA     = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB     = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+		keyU     = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+		internal = "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"
+		hash     = "0101010101010101010101010101010101010101010101010101010101010101"
+	)
+
+	// and_v(v:older(1),...) adds 2 opcodes per level, 221 in total.
+	deep := "pk(" + keyA + ")"
+	for i := 0; i < 110; i++ {
+		deep = "and_v(v:older(1)," + deep + ")"
+	}
+
+	testCases := []struct {
+		name string
+		desc string
+		err  string
+	}{
+		{"invalid type", "wsh(and_v(pk(" + keyA + "),pk(" + keyB + ")))", "has an invalid type"},
+		{"top level not B", "wsh(v:pk(" + keyA + "))", "top level expression must be of type B"},
+		{"no signature", "wsh(sha256(" + hash + "))", "can be spent without a signature"},
+		{"malleable", "wsh(and_v(v:pk(" + keyA + "),or_d(sha256(" + hash + "),older(10))))", "is malleable"},
+		{"timelock mix", "wsh(and_v(v:after(100),and_v(v:after(500000001),pk(" + keyA + "))))", "mixes height and time based timelocks"},
+		{"multi_a in wsh", "wsh(and_v(v:pk(" + keyA + "),multi_a(1," + keyB + ")))", "multi_a() is only allowed in tapscript"},
+		{"multi in tapscript", "tr(" + internal + ",and_v(v:pk(" + keyA + "),multi(1," + keyB + ")))", "multi() is not allowed in tapscript"},
+		{"zero timelock", "wsh(and_v(v:pk(" + keyA + "),older(0)))", "invalid older() value '0'"},
+		{"timelock overflow", "wsh(and_v(v:pk(" + keyA + "),older(2147483648)))", "invalid older() value '2147483648'"},
+		{"short hash", "wsh(and_v(v:pk(" + keyA + "),sha256(0101)))", "invalid sha256() hash '0101'"},
+		{"invalid wrapper", "wsh(and_v(x:pk(" + keyA + "),older(1)))", "invalid miniscript wrapper 'x'"},
+		{"missing wrapper", "wsh(and_v(:pk(" + keyA + "),older(1)))", "missing miniscript wrappers"},
+		{"unknown fragment", "wsh(and_v(v:pk(" + keyA + "),foo(1)))", "invalid miniscript fragment 'foo'"},
+		{"thresh threshold", "wsh(thresh(3,pk(" + keyA + "),s:pk(" + keyB + ")))", "invalid threshold '3'"},
+		{"uncompressed key", "wsh(and_v(v:pk(" + keyU + "),older(1)))", "must be compressed"},
+		{"outside wsh", "sh(and_v(v:pk(" + keyA + "),older(1)))", "invalid op 'and_v'"},
+		{"duplicate key", "wsh(and_v(v:pk(" + keyA + "),pk(" + keyA + ")))", "duplicate key"},
+		{"duplicate x-only key", "tr(" + internal + ",and_v(v:pk(" + keyA + "),pk(" + keyA[2:] + ")))", "duplicate key"},
+		{"duplicate multi key", "wsh(or_d(pk(" + keyA + "),multi(1," + keyB + "," + keyA + ")))", "duplicate key"},
+		{"ops limit", "wsh(" + deep + ")", "more than the 201 opcodes limit"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.desc)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+// TestMiniscriptOpsLimit checks the opcodes count of P2WSH miniscripts,
+// which includes the keys of the executed OP_CHECKMULTISIG.
+func TestMiniscriptOpsLimit(t *testing.T) {
+	keys := make([]string, 20)
+	for i := range keys {
+		priv := make([]byte, 32)
+		priv[31] = byte(i + 1)
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), priv)
+		keys[i] = hex.EncodeToString(pub.SerializeCompressed())
+	}
+	multi := "multi(1," + strings.Join(keys, ",") + ")"
+
+	// multi() takes 1 + 20 opcodes and each and_v(v:older(1),...) 2 more.
+	nested := func(levels int) string {
+		ms := multi
+		for i := 0; i < levels; i++ {
+			ms = "and_v(v:older(1)," + ms + ")"
+		}
+		return "wsh(" + ms + ")"
+	}
+
+	_, err := Parse(nested(90))
+	require.NoError(t, err)
+
+	_, err = Parse(nested(91))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "executes 203 opcodes")
+}
+
+func TestMiniscriptTypes(t *testing.T) {
+	testCases := []struct {
+		ms       string
+		expected string
+	}{
+		{"pk_k(A)", "Konudemsxk"},
+		{"pk(A)", "Bondusemk"},
+		{"older(1)", "Bzfmxhk"},
+		{"older(4194305)", "Bzfmxgk"},
+		{"after(500000000)", "Bzfmxik"},
+		{"v:pk(A)", "Vonfsmxk"},
+		{"s:pk(A)", "Wdusemk"},
+		{"sln:older(10)", "Wdumexhk"},
+	}
+
+	for _, test := range testCases {
+		ms, err := ParseMiniscript(strings.ReplaceAll(test.ms, "A", "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"))
+		require.NoError(t, err)
+
+		typ, err := ms.(*miniscript).root.typ(msP2WSH)
+		require.NoError(t, err)
+		assert.Equal(t, mst(test.expected).String(), typ.String(), test.ms)
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// multipathRegexp matches a BIP389 `<a;b;...>` multipath step.
+var multipathRegexp = regexp.MustCompile(`<([^<>]*)>`)
+
+// IsMultipath returns if a descriptor has multipath steps.
+func IsMultipath(s string) bool {
+	return multipathRegexp.MatchString(s)
+}
+
+// ExpandMultipath expands a BIP389 multipath descriptor into one descriptor
+// per multipath alternative, e.g. `wpkh(xpub/<0;1>/*)` expands into the
+// receive `wpkh(xpub/0/*)` and change `wpkh(xpub/1/*)` descriptors.
+// Descriptors without multipath steps are returned as they are.
+func ExpandMultipath(s string) ([]string, error) {
+	desc, checksum, err := splitChecksum(s)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := multipathRegexp.FindAllStringSubmatchIndex(desc, -1)
+	if len(matches) == 0 {
+		return []string{s}, nil
+	}
+
+	var steps [][]string
+	for i, match := range matches {
+		// Each key can have at most one multipath step, keys are delimited by
+		// any of the descriptor delimiters.
+		if i > 0 && !strings.ContainsAny(desc[matches[i-1][1]:match[0]], "(){},") {
+			return nil, errors.New("multipath: a key can't have more than one multipath step")
+		}
+
+		alternatives, err := parseMultipathStep(desc[match[2]:match[3]])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(steps) > 0 && len(steps[0]) != len(alternatives) {
+			return nil, errors.New("multipath: all multipath steps must have the same length")
+		}
+		steps = append(steps, alternatives)
+	}
+
+	descs := make([]string, len(steps[0]))
+	for i := range descs {
+		var (
+			sb   strings.Builder
+			last int
+		)
+		for j, match := range matches {
+			sb.WriteString(desc[last:match[0]])
+			sb.WriteString(steps[j][i])
+			last = match[1]
+		}
+		sb.WriteString(desc[last:])
+
+		descs[i] = sb.String()
+		if checksum != "" {
+			descs[i] = withChecksum(descs[i])
+		}
+	}
+
+	return descs, nil
+}
+
+// parseMultipathStep parses the `a;b;...` content of a multipath step.
+func parseMultipathStep(s string) ([]string, error) {
+	alternatives := strings.Split(s, ";")
+	if len(alternatives) < 2 {
+		return nil, fmt.Errorf("multipath: step <%s> needs at least two alternatives", s)
+	}
+
+	seen := make(map[string]bool)
+	for _, alt := range alternatives {
+		index := strings.TrimRight(alt, "'hH")
+		if _, err := strconv.ParseUint(index, 10, 31); err != nil || len(alt)-len(index) > 1 {
+			return nil, fmt.Errorf("multipath: invalid step '%s'", alt)
+		}
+
+		// h, H and ' are equivalent
+		if len(alt) > len(index) {
+			index += "'"
+		}
+		if seen[index] {
+			return nil, fmt.Errorf("multipath: duplicated step '%s'", alt)
+		}
+		seen[index] = true
+	}
+
+	return alternatives, nil
+}
@@ -0,0 +1,92 @@
+package script_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/qustavo/go-wallet/script"
+)
+
+func TestExpandMultipath(t *testing.T) {
+	const (
+		xpub1 = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+		xpub2 = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	)
+
+	testCases := []struct {
+		name     string
+		desc     string
+		expected []string
+	}{
+		{
+			name:     "receive and change",
+			desc:     "wpkh([3442193e/84'/0'/0']" + xpub1 + "/<0;1>/*)",
+			expected: []string{"wpkh([3442193e/84'/0'/0']" + xpub1 + "/0/*)", "wpkh([3442193e/84'/0'/0']" + xpub1 + "/1/*)"},
+		},
+		{
+			name: "multiple keys",
+			desc: "wsh(multi(2," + xpub1 + "/<0;1;2>/*," + xpub2 + "/<3;4;5>/0/*))",
+			expected: []string{
+				"wsh(multi(2," + xpub1 + "/0/*," + xpub2 + "/3/0/*))",
+				"wsh(multi(2," + xpub1 + "/1/*," + xpub2 + "/4/0/*))",
+				"wsh(multi(2," + xpub1 + "/2/*," + xpub2 + "/5/0/*))",
+			},
+		},
+		{
+			name:     "no multipath",
+			desc:     "wpkh(" + xpub1 + "/0/*)",
+			expected: []string{"wpkh(" + xpub1 + "/0/*)"},
+		},
+		{
+			name:     "checksum",
+			desc:     "raw(deadbeef)#89f8spxm",
+			expected: []string{"raw(deadbeef)#89f8spxm"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			descs, err := ExpandMultipath(test.desc)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, descs)
+
+			for _, desc := range descs {
+				_, err := Parse(desc)
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	withChecksum, err := AddChecksum("wpkh(" + xpub1 + "/<0;1>/*)")
+	require.NoError(t, err)
+
+	descs, err := ExpandMultipath(withChecksum)
+	require.NoError(t, err)
+	for _, desc := range descs {
+		_, err := Parse(desc)
+		require.NoError(t, err, "expanded descriptors must have a valid checksum")
+	}
+}
+
+func TestInvalidMultipath(t *testing.T) {
+	const xpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+
+	for _, desc := range []string{
+		"wpkh(" + xpub + "/<0>/*)",
+		"wpkh(" + xpub + "/<0;0>/*)",
+		"wpkh(" + xpub + "/<0;0h>/<1;1h>)",
+		"wpkh(" + xpub + "/<0;1>/<2;3>/*)",
+		"wpkh(" + xpub + "/<0;x>/*)",
+		"wpkh(" + xpub + "/<0;1''>/*)",
+		"wpkh(" + xpub + "/<0;2147483648>/*)",
+		"wsh(multi(1," + xpub + "/<0;1>/*," + xpub + "/<0;1;2>/*))",
+	} {
+		_, err := ExpandMultipath(desc)
+		assert.Error(t, err, desc)
+	}
+
+	_, err := Parse("wpkh(" + xpub + "/<0;1>/*)")
+	assert.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// NetParams are the parameters of a Bitcoin network needed to encode its
+// addresses and keys.
+type NetParams struct {
+	// Name identifies the network, e.g. in the command line.
+	Name string
+
+	// PubKeyHashAddrID and ScriptHashAddrID are the base58 prefixes of P2PKH
+	// and P2SH addresses, and PrivateKeyID the one of WIF private keys.
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+	PrivateKeyID     byte
+
+	// Bech32HRP is the human readable part of segwit addresses.
+	Bech32HRP string
+
+	// HDPublicKeyID and HDPrivateKeyID are the version bytes of BIP32
+	// extended keys.
+	HDPublicKeyID  [4]byte
+	HDPrivateKeyID [4]byte
+
+	// GenesisHash is the hash of the genesis block in block header order,
+	// which is the reverse of its usual hex representation.
+	GenesisHash [32]byte
+}
+
+// Network is the former type of networks, kept as an alias of *NetParams so
+// existing callers keep compiling.
+type Network = *NetParams
+
+// ErrUnknownNetwork is returned when a nil network is given.
+var ErrUnknownNetwork = errors.New("unknown network")
+
+func (p *NetParams) String() string {
+	return p.Name
+}
+
+var (
+	Mainnet = &NetParams{
+		Name:             "mainnet",
+		PubKeyHashAddrID: 0x00,
+		ScriptHashAddrID: 0x05,
+		PrivateKeyID:     0x80,
+		Bech32HRP:        "bc",
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e}, // xpub
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4}, // xprv
+		GenesisHash:      genesisHash("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+	}
+
+	Testnet = &NetParams{
+		Name:             "testnet",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		Bech32HRP:        "tb",
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf}, // tpub
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94}, // tprv
+		GenesisHash:      genesisHash("000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"),
+	}
+
+	// Signet is the default BIP325 signet, which shares its prefixes with
+	// Testnet.
+	Signet = &NetParams{
+		Name:             "signet",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		Bech32HRP:        "tb",
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf}, // tpub
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94}, // tprv
+		GenesisHash:      genesisHash("00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"),
+	}
+
+	Regtest = &NetParams{
+		Name:             "regtest",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		Bech32HRP:        "bcrt",
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf}, // tpub
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94}, // tprv
+		GenesisHash:      genesisHash("0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"),
+	}
+)
+
+// DefaultNetwork is the network used when none is specified.
+var DefaultNetwork = Mainnet
+
+// genesisHash returns the hash of a genesis block given its hex
+// representation.
+func genesisHash(s string) [32]byte {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		panic("invalid genesis hash " + s)
+	}
+
+	var hash [32]byte
+	for i := range b {
+		hash[i] = b[len(b)-1-i]
+	}
+	return hash
+}
+
+var (
+	networksMu sync.RWMutex
+	// networks are the registered networks, the built-in ones first.
+	networks = []*NetParams{Mainnet, Testnet, Signet, Regtest}
+)
+
+// ErrDuplicateNetwork is returned when registering a network whose name is
+// already taken.
+var ErrDuplicateNetwork = errors.New("network already registered")
+
+// RegisterNetwork registers the parameters of a custom network, which makes
+// its addresses decodable by Addr() and its extended keys usable in
+// descriptors. Network names must be unique.
+func RegisterNetwork(params *NetParams) error {
+	if params == nil {
+		return ErrUnknownNetwork
+	}
+	if params.Name == "" {
+		return errors.New("network name is empty")
+	}
+	if params.Bech32HRP == "" {
+		return fmt.Errorf("network '%s' has no bech32 hrp", params.Name)
+	}
+
+	networksMu.Lock()
+	defer networksMu.Unlock()
+
+	for _, net := range networks {
+		if net.Name == params.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateNetwork, params.Name)
+		}
+	}
+
+	networks = append(networks, params)
+	return nil
+}
+
+// NetworkByName returns the registered network with the given name.
+func NetworkByName(name string) (*NetParams, error) {
+	for _, net := range Networks() {
+		if net.Name == name {
+			return net, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network '%s'", name)
+}
+
+// Networks returns the registered networks, the built-in ones first.
+func Networks() []*NetParams {
+	networksMu.RLock()
+	defer networksMu.RUnlock()
+
+	return append([]*NetParams(nil), networks...)
+}
+
+// hdPublicKeyID returns the version bytes of the extended public keys
+// matching the given extended private key version, if any registered network
+// uses it.
+func hdPublicKeyID(privID []byte) ([]byte, bool) {
+	for _, net := range Networks() {
+		if bytes.Equal(net.HDPrivateKeyID[:], privID) {
+			return net.HDPublicKeyID[:], true
+		}
+	}
+	return nil, false
+}
+
+// isWIFPrefix returns if a registered network uses the prefix for its WIF
+// private keys.
+func isWIFPrefix(prefix byte) bool {
+	for _, net := range Networks() {
+		if net.PrivateKeyID == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// isHDKeyID returns if a registered network uses the version bytes for its
+// extended public or private keys.
+func isHDKeyID(version []byte) bool {
+	for _, net := range Networks() {
+		if bytes.Equal(net.HDPublicKeyID[:], version) || bytes.Equal(net.HDPrivateKeyID[:], version) {
+			return true
+		}
+	}
+	return false
+}
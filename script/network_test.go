@@ -0,0 +1,163 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// customNet is a network with its own prefixes and extended key versions.
+var customNet = &NetParams{
+	Name:             "customnet",
+	PubKeyHashAddrID: 0x1c,
+	ScriptHashAddrID: 0x1d,
+	PrivateKeyID:     0x9c,
+	Bech32HRP:        "cust",
+	HDPublicKeyID:    [4]byte{0x01, 0x02, 0x03, 0x04},
+	HDPrivateKeyID:   [4]byte{0x01, 0x02, 0x03, 0x05},
+}
+
+var registerCustomNet sync.Once
+
+// withCustomNet registers customNet once, as networks can't be unregistered.
+func withCustomNet(t *testing.T) {
+	registerCustomNet.Do(func() {
+		require.NoError(t, RegisterNetwork(customNet))
+	})
+}
+
+func TestBuiltinNetworks(t *testing.T) {
+	for _, net := range []*NetParams{Mainnet, Testnet, Signet, Regtest} {
+		found, err := NetworkByName(net.Name)
+		require.NoError(t, err)
+		assert.Same(t, net, found)
+	}
+
+	_, err := NetworkByName("testnet4")
+	assert.Error(t, err)
+
+	// Genesis hashes are stored in block header order.
+	assert.Equal(t, "6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000", hex.EncodeToString(Mainnet.GenesisHash[:]))
+	assert.NotEqual(t, Testnet.GenesisHash, Signet.GenesisHash)
+}
+
+func TestRegisterNetwork(t *testing.T) {
+	withCustomNet(t)
+
+	found, err := NetworkByName("customnet")
+	require.NoError(t, err)
+	assert.Same(t, customNet, found)
+	assert.Contains(t, Networks(), customNet)
+
+	err = RegisterNetwork(&NetParams{Name: "customnet", Bech32HRP: "other"})
+	assert.ErrorIs(t, err, ErrDuplicateNetwork)
+	err = RegisterNetwork(&NetParams{Name: "mainnet", Bech32HRP: "bc"})
+	assert.ErrorIs(t, err, ErrDuplicateNetwork)
+	assert.Error(t, RegisterNetwork(&NetParams{Bech32HRP: "x"}))
+	assert.Error(t, RegisterNetwork(&NetParams{Name: "nohrp"}))
+}
+
+func TestCustomNetworkAddresses(t *testing.T) {
+	withCustomNet(t)
+
+	const key = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	for _, desc := range []string{"pkh(" + key + ")", "sh(wpkh(" + key + "))", "wpkh(" + key + ")", "tr(" + key + ")"} {
+		script, err := Parse(desc)
+		require.NoError(t, err)
+
+		addr, err := script.EncodeAddress(customNet)
+		require.NoError(t, err)
+
+		decoded, err := DecodeAddress(addr, customNet)
+		require.NoError(t, err)
+		assert.Equal(t, script.Bytes(), decoded)
+
+		// Addresses of registered networks are understood by addr().
+		eval, err := Addr(addr).Eval()
+		require.NoError(t, err, addr)
+		assert.Equal(t, script.Bytes(), eval.Bytes())
+	}
+
+	script, err := Parse("wpkh(" + key + ")")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(script.Address(customNet), "cust1q"))
+}
+
+func TestCustomNetworkExtendedKeys(t *testing.T) {
+	withCustomNet(t)
+
+	// BIP32 test vector 1, m.
+	const (
+		xprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+		xpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+	)
+	withVersion := func(s string, version [4]byte) string {
+		key, err := hdkeychain.NewKeyFromString(s)
+		require.NoError(t, err)
+		key, err = key.CloneWithVersion(version[:])
+		require.NoError(t, err)
+		return key.String()
+	}
+	customPrv := withVersion(xprv, customNet.HDPrivateKeyID)
+	customPub := withVersion(xpub, customNet.HDPublicKeyID)
+
+	assert.True(t, IsXPub(customPrv))
+	assert.True(t, IsXPub(customPub+"/0/*"))
+
+	expected, err := Parse("wpkh(" + xpub + "/0/1)")
+	require.NoError(t, err)
+	script, err := Parse("wpkh(" + customPub + "/0/1)")
+	require.NoError(t, err)
+	assert.Equal(t, expected.Bytes(), script.Bytes())
+
+	desc, err := PublicDescriptor("wpkh(" + customPrv + "/0/*)")
+	require.NoError(t, err)
+	assert.Equal(t, "wpkh("+customPub+"/0/*)", strings.Split(desc, "#")[0])
+}
+
+func TestNilNetwork(t *testing.T) {
+	script, err := Parse("wpkh(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)")
+	require.NoError(t, err)
+
+	_, err = script.EncodeAddress(nil)
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+	assert.Equal(t, "", script.Address(nil))
+
+	_, err = DecodeAddress("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", nil)
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+	assert.ErrorIs(t, RegisterNetwork(nil), ErrUnknownNetwork)
+
+	// The former Network type is an alias.
+	var net Network = Mainnet
+	assert.Equal(t, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", script.Address(net))
+}
+
+func TestWIFPrefixes(t *testing.T) {
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{1}, 32))
+	wif := func(prefix byte) string {
+		w, err := btcutil.NewWIF(priv, &chaincfg.Params{PrivateKeyID: prefix}, true)
+		require.NoError(t, err)
+		return w.String()
+	}
+
+	for _, prefix := range []byte{Mainnet.PrivateKeyID, Testnet.PrivateKeyID} {
+		_, err := NewPrivKey(wif(prefix))
+		assert.NoError(t, err)
+	}
+
+	withCustomNet(t)
+	_, err := NewPrivKey(wif(customNet.PrivateKeyID))
+	assert.NoError(t, err)
+
+	_, err = NewPrivKey(wif(0x42))
+	assert.Error(t, err)
+}
@@ -1,23 +1,129 @@
 package script
 
+import "strconv"
+
 const (
 	// Push value onto stack
-	OP_0     = 0x00
-	OP_FALSE = OP_0
-	OP_1     = 0x51
-	OP_TRUE  = 0x01
+	OP_0         = 0x00
+	OP_FALSE     = OP_0
+	OP_PUSHDATA1 = 0x4C
+	OP_PUSHDATA2 = 0x4D
+	OP_PUSHDATA4 = 0x4E
+	OP_1NEGATE   = 0x4F
+	OP_RESERVED  = 0x50
+	OP_1         = 0x51
+	OP_TRUE      = OP_1
+	OP_16        = 0x60
+
+	// Flow control
+	OP_NOP      = 0x61
+	OP_VER      = 0x62
+	OP_IF       = 0x63
+	OP_NOTIF    = 0x64
+	OP_VERIF    = 0x65
+	OP_VERNOTIF = 0x66
+	OP_ELSE     = 0x67
+	OP_ENDIF    = 0x68
+	OP_VERIFY   = 0x69
+	OP_RETURN   = 0x6A
 
 	// Stack Operation
-	OP_DUP = 0x76
+	OP_TOALTSTACK   = 0x6B
+	OP_FROMALTSTACK = 0x6C
+	OP_2DROP        = 0x6D
+	OP_2DUP         = 0x6E
+	OP_3DUP         = 0x6F
+	OP_2OVER        = 0x70
+	OP_2ROT         = 0x71
+	OP_2SWAP        = 0x72
+	OP_IFDUP        = 0x73
+	OP_DEPTH        = 0x74
+	OP_DROP         = 0x75
+	OP_DUP          = 0x76
+	OP_NIP          = 0x77
+	OP_OVER         = 0x78
+	OP_PICK         = 0x79
+	OP_ROLL         = 0x7A
+	OP_ROT          = 0x7B
+	OP_SWAP         = 0x7C
+	OP_TUCK         = 0x7D
 
-	// Binary arithmetic and conditionals
+	// Splice operations
+	OP_CAT    = 0x7E
+	OP_SUBSTR = 0x7F
+	OP_LEFT   = 0x80
+	OP_RIGHT  = 0x81
+	OP_SIZE   = 0x82
+
+	// Bit logic
+	OP_INVERT      = 0x83
+	OP_AND         = 0x84
+	OP_OR          = 0x85
+	OP_XOR         = 0x86
 	OP_EQUAL       = 0x87
 	OP_EQUALVERIFY = 0x88
+	OP_RESERVED1   = 0x89
+	OP_RESERVED2   = 0x8A
+
+	// Binary arithmetic and conditionals
+	OP_1ADD               = 0x8B
+	OP_1SUB               = 0x8C
+	OP_2MUL               = 0x8D
+	OP_2DIV               = 0x8E
+	OP_NEGATE             = 0x8F
+	OP_ABS                = 0x90
+	OP_NOT                = 0x91
+	OP_0NOTEQUAL          = 0x92
+	OP_ADD                = 0x93
+	OP_SUB                = 0x94
+	OP_MUL                = 0x95
+	OP_DIV                = 0x96
+	OP_MOD                = 0x97
+	OP_LSHIFT             = 0x98
+	OP_RSHIFT             = 0x99
+	OP_BOOLAND            = 0x9A
+	OP_BOOLOR             = 0x9B
+	OP_NUMEQUAL           = 0x9C
+	OP_NUMEQUALVERIFY     = 0x9D
+	OP_NUMNOTEQUAL        = 0x9E
+	OP_LESSTHAN           = 0x9F
+	OP_GREATERTHAN        = 0xA0
+	OP_LESSTHANOREQUAL    = 0xA1
+	OP_GREATERTHANOREQUAL = 0xA2
+	OP_MIN                = 0xA3
+	OP_MAX                = 0xA4
+	OP_WITHIN             = 0xA5
 
 	// Cryptographic and hashing operations
-	OP_HASH160       = 0xA9
-	OP_CHECKSIG      = 0xAC
-	OP_CHECKMULTISIG = 0xAE
+	OP_RIPEMD160           = 0xA6
+	OP_SHA1                = 0xA7
+	OP_SHA256              = 0xA8
+	OP_HASH160             = 0xA9
+	OP_HASH256             = 0xAA
+	OP_CODESEPARATOR       = 0xAB
+	OP_CHECKSIG            = 0xAC
+	OP_CHECKSIGVERIFY      = 0xAD
+	OP_CHECKMULTISIG       = 0xAE
+	OP_CHECKMULTISIGVERIFY = 0xAF
+
+	// Expansion
+	OP_NOP1                = 0xB0
+	OP_CHECKLOCKTIMEVERIFY = 0xB1
+	OP_NOP2                = OP_CHECKLOCKTIMEVERIFY
+	OP_CHECKSEQUENCEVERIFY = 0xB2
+	OP_NOP3                = OP_CHECKSEQUENCEVERIFY
+	OP_NOP4                = 0xB3
+	OP_NOP5                = 0xB4
+	OP_NOP6                = 0xB5
+	OP_NOP7                = 0xB6
+	OP_NOP8                = 0xB7
+	OP_NOP9                = 0xB8
+	OP_NOP10               = 0xB9
+
+	// Tapscript
+	OP_CHECKSIGADD = 0xBA
+
+	OP_INVALIDOPCODE = 0xFF
 )
 
 func OP_PUSH_BYTES(b int) byte {
@@ -28,8 +134,131 @@ func OP_PUSH_BYTES(b int) byte {
 }
 
 func OP_N(n int) byte {
-	if n < 0x02 || n > 0x10 {
-		panic("OP_N value MUST be between 0x02 and 0x10")
+	if n < 0x01 || n > 0x10 {
+		panic("OP_N value MUST be between 0x01 and 0x10")
 	}
 	return byte(0x50 + n)
 }
+
+// opcodeNames are the names of the opcodes as printed by Bitcoin Core, the
+// small integers are named by their value.
+var opcodeNames = map[byte]string{
+	OP_0:         "0",
+	OP_PUSHDATA1: "OP_PUSHDATA1",
+	OP_PUSHDATA2: "OP_PUSHDATA2",
+	OP_PUSHDATA4: "OP_PUSHDATA4",
+	OP_1NEGATE:   "-1",
+	OP_RESERVED:  "OP_RESERVED",
+
+	OP_NOP:      "OP_NOP",
+	OP_VER:      "OP_VER",
+	OP_IF:       "OP_IF",
+	OP_NOTIF:    "OP_NOTIF",
+	OP_VERIF:    "OP_VERIF",
+	OP_VERNOTIF: "OP_VERNOTIF",
+	OP_ELSE:     "OP_ELSE",
+	OP_ENDIF:    "OP_ENDIF",
+	OP_VERIFY:   "OP_VERIFY",
+	OP_RETURN:   "OP_RETURN",
+
+	OP_TOALTSTACK:   "OP_TOALTSTACK",
+	OP_FROMALTSTACK: "OP_FROMALTSTACK",
+	OP_2DROP:        "OP_2DROP",
+	OP_2DUP:         "OP_2DUP",
+	OP_3DUP:         "OP_3DUP",
+	OP_2OVER:        "OP_2OVER",
+	OP_2ROT:         "OP_2ROT",
+	OP_2SWAP:        "OP_2SWAP",
+	OP_IFDUP:        "OP_IFDUP",
+	OP_DEPTH:        "OP_DEPTH",
+	OP_DROP:         "OP_DROP",
+	OP_DUP:          "OP_DUP",
+	OP_NIP:          "OP_NIP",
+	OP_OVER:         "OP_OVER",
+	OP_PICK:         "OP_PICK",
+	OP_ROLL:         "OP_ROLL",
+	OP_ROT:          "OP_ROT",
+	OP_SWAP:         "OP_SWAP",
+	OP_TUCK:         "OP_TUCK",
+
+	OP_CAT:    "OP_CAT",
+	OP_SUBSTR: "OP_SUBSTR",
+	OP_LEFT:   "OP_LEFT",
+	OP_RIGHT:  "OP_RIGHT",
+	OP_SIZE:   "OP_SIZE",
+
+	OP_INVERT:      "OP_INVERT",
+	OP_AND:         "OP_AND",
+	OP_OR:          "OP_OR",
+	OP_XOR:         "OP_XOR",
+	OP_EQUAL:       "OP_EQUAL",
+	OP_EQUALVERIFY: "OP_EQUALVERIFY",
+	OP_RESERVED1:   "OP_RESERVED1",
+	OP_RESERVED2:   "OP_RESERVED2",
+
+	OP_1ADD:               "OP_1ADD",
+	OP_1SUB:               "OP_1SUB",
+	OP_2MUL:               "OP_2MUL",
+	OP_2DIV:               "OP_2DIV",
+	OP_NEGATE:             "OP_NEGATE",
+	OP_ABS:                "OP_ABS",
+	OP_NOT:                "OP_NOT",
+	OP_0NOTEQUAL:          "OP_0NOTEQUAL",
+	OP_ADD:                "OP_ADD",
+	OP_SUB:                "OP_SUB",
+	OP_MUL:                "OP_MUL",
+	OP_DIV:                "OP_DIV",
+	OP_MOD:                "OP_MOD",
+	OP_LSHIFT:             "OP_LSHIFT",
+	OP_RSHIFT:             "OP_RSHIFT",
+	OP_BOOLAND:            "OP_BOOLAND",
+	OP_BOOLOR:             "OP_BOOLOR",
+	OP_NUMEQUAL:           "OP_NUMEQUAL",
+	OP_NUMEQUALVERIFY:     "OP_NUMEQUALVERIFY",
+	OP_NUMNOTEQUAL:        "OP_NUMNOTEQUAL",
+	OP_LESSTHAN:           "OP_LESSTHAN",
+	OP_GREATERTHAN:        "OP_GREATERTHAN",
+	OP_LESSTHANOREQUAL:    "OP_LESSTHANOREQUAL",
+	OP_GREATERTHANOREQUAL: "OP_GREATERTHANOREQUAL",
+	OP_MIN:                "OP_MIN",
+	OP_MAX:                "OP_MAX",
+	OP_WITHIN:             "OP_WITHIN",
+
+	OP_RIPEMD160:           "OP_RIPEMD160",
+	OP_SHA1:                "OP_SHA1",
+	OP_SHA256:              "OP_SHA256",
+	OP_HASH160:             "OP_HASH160",
+	OP_HASH256:             "OP_HASH256",
+	OP_CODESEPARATOR:       "OP_CODESEPARATOR",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+
+	OP_NOP1:                "OP_NOP1",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+	OP_CHECKSEQUENCEVERIFY: "OP_CHECKSEQUENCEVERIFY",
+	OP_NOP4:                "OP_NOP4",
+	OP_NOP5:                "OP_NOP5",
+	OP_NOP6:                "OP_NOP6",
+	OP_NOP7:                "OP_NOP7",
+	OP_NOP8:                "OP_NOP8",
+	OP_NOP9:                "OP_NOP9",
+	OP_NOP10:               "OP_NOP10",
+
+	OP_CHECKSIGADD: "OP_CHECKSIGADD",
+
+	OP_INVALIDOPCODE: "OP_INVALIDOPCODE",
+}
+
+// OpcodeName returns the name of an opcode as printed by Bitcoin Core,
+// OP_UNKNOWN for undefined opcodes and `N` for OP_1 to OP_16.
+func OpcodeName(op byte) string {
+	if op >= OP_1 && op <= OP_16 {
+		return strconv.Itoa(int(op-OP_1) + 1)
+	}
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return "OP_UNKNOWN"
+}
@@ -1,161 +1,514 @@
 package script
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
-// parses a expr(args) form.
-var exprRegex = regexp.MustCompile(`(\w+)\((.+)\)`)
+// ParseError is returned when a descriptor can't be parsed, Offset is the
+// byte offset in the input where the error was found.
+type ParseError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid descriptor at offset %d: %s", e.Offset, e.Msg)
+}
+
+func errorAt(pos int, format string, args ...interface{}) error {
+	return &ParseError{Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func unexpected(tok token, expected ...string) error {
+	return errorAt(tok.pos, "unexpected %s, expected %s", tok, strings.Join(expected, " or "))
+}
+
+// maxParseDepth limits the nesting of descriptor expressions.
+const maxParseDepth = 2 * maxTapTreeDepth
+
+type nodeKind int
+
+const (
+	// nodeWord is a bare argument such as a key, a number or an address.
+	nodeWord nodeKind = iota
+	// nodeCall is an `name(args...)` expression.
+	nodeCall
+	// nodeTree is a `{left,right}` Taproot tree branch.
+	nodeTree
+)
 
-func splitOpAndArgs(s string) (string, string, error) {
-	// remove non printable characters
-	s = strings.Map(func(r rune) rune {
-		if unicode.IsSpace(r) {
-			return -1
+// node is a node of the descriptor syntax tree.
+type node struct {
+	kind nodeKind
+	name string
+	args []*node
+	pos  int
+}
+
+// String returns the descriptor text of the node.
+func (n *node) String() string {
+	switch n.kind {
+	case nodeCall:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			args[i] = arg.String()
 		}
-		return r
-	}, s)
+		return n.name + "(" + strings.Join(args, ",") + ")"
+	case nodeTree:
+		return "{" + n.args[0].String() + "," + n.args[1].String() + "}"
+	}
+	return n.name
+}
+
+// parser is a recursive descent parser for the descriptor grammar:
+//
+//	EXPR := WORD | WORD '(' [ EXPR { ',' EXPR } ] ')' | '{' EXPR ',' EXPR '}'
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
 
-	matches := exprRegex.FindStringSubmatch(s)
-	if matches == nil {
-		return "", "", errors.New("invalid script")
+func (p *parser) expect(kind tokenKind) error {
+	if tok := p.next(); tok.kind != kind {
+		return unexpected(tok, kind.String())
 	}
+	return nil
+}
+
+// parseDescriptor parses s into a syntax tree.
+func parseDescriptor(s string) (*node, error) {
+	p := &parser{tokens: tokenize(s)}
 
-	return matches[1], matches[2], nil
+	n, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
 
+	if err := p.expect(tokEOF); err != nil {
+		return nil, err
+	}
+
+	return n, nil
 }
 
-func parseScript(s, path string) (ScriptExpr, error) {
-	return parseScriptR(s, path, true)
+func (p *parser) parseExpr(depth int) (*node, error) {
+	tok := p.next()
+	if depth > maxParseDepth {
+		return nil, errorAt(tok.pos, "expression exceeds maximum depth")
+	}
+
+	switch tok.kind {
+	case tokLBrace:
+		left, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokComma); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokRBrace); err != nil {
+			return nil, err
+		}
+
+		return &node{kind: nodeTree, args: []*node{left, right}, pos: tok.pos}, nil
+	case tokWord:
+		if p.peek().kind != tokLParen {
+			return &node{kind: nodeWord, name: tok.val, pos: tok.pos}, nil
+		}
+		p.next()
+
+		n := &node{kind: nodeCall, name: tok.val, pos: tok.pos}
+		if p.peek().kind == tokRParen {
+			p.next()
+			return n, nil
+		}
+
+		for {
+			arg, err := p.parseExpr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, arg)
+
+			switch tok := p.next(); tok.kind {
+			case tokComma:
+			case tokRParen:
+				return n, nil
+			default:
+				return nil, unexpected(tok, tokComma.String(), tokRParen.String())
+			}
+		}
+	}
+
+	return nil, unexpected(tok, "expression")
 }
 
-func deriveIfXpub(s, path string) (string, error) {
-	// Remove the [hex/path] origin if present.
-	s = trimKeyOrigin(s)
+func parseScript(s, path string) (ScriptExpr, error) {
+	desc, _, err := splitChecksum(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// splitChecksum trims the input, keep the leading whitespace so that
+	// error offsets match the input.
+	lead := len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
 
-	if !IsXPub(s) {
-		return s, nil
+	n, err := parseDescriptor(s[:lead+len(desc)])
+	if err != nil {
+		return nil, err
 	}
 
-	xpub, err := NewXPub(s)
+	return compile(n, path, ctxTop)
+}
+
+// PublicDescriptor returns the descriptor with all of its private keys (WIF
+// and extended private keys) replaced by their public keys, so it can be
+// shared without leaking private material. It fails if a private key has
+// hardened levels which can't be derived from the public key, like `/*'`.
+func PublicDescriptor(s string) (string, error) {
+	desc, _, err := splitChecksum(s)
 	if err != nil {
 		return "", err
 	}
 
-	if path != "" {
-		xpub, err = xpub.Derive(path)
-		if err != nil {
-			return "", err
+	n, err := parseDescriptor(desc)
+	if err != nil {
+		return "", err
+	}
+
+	var redact func(n *node) error
+	redact = func(n *node) error {
+		if n.kind == nodeWord {
+			key, err := publicKeyExpr(n.name)
+			if err != nil {
+				return errorAt(n.pos, "%s", err)
+			}
+			n.name = key
+			return nil
 		}
+
+		for _, arg := range n.args {
+			if err := redact(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := redact(n); err != nil {
+		return "", err
 	}
 
-	pub, err := xpub.PubKey()
-	if err != nil {
-		return "", nil
+	return withChecksum(n.String()), nil
+}
+
+// applyPath derives an extended key expression with the given path. Ranged
+// keys (ending in `/*`) get the wildcard replaced by the path levels, other
+// keys get the levels appended. Non extended keys are returned as they are.
+func applyPath(key, path string) (string, error) {
+	if path == "" || !IsXPub(trimKeyOrigin(key)) {
+		return key, nil
 	}
 
-	return pub, nil
+	p, err := ParseDerivationPath(path)
+	if err != nil || len(p) == 0 {
+		return key, err
+	}
+
+	for _, wildcard := range []string{"/*'", "/*h", "/*H", "/*"} {
+		if strings.HasSuffix(key, wildcard) {
+			// A hardened wildcard hardens the last level of the path.
+			if wildcard != "/*" {
+				p[len(p)-1] |= HardenedKeyStart
+			}
+			return strings.TrimSuffix(key, wildcard) + p.levels(), nil
+		}
+	}
+
+	return key + p.levels(), nil
+}
+
+// parseCtx is the context in which an expression is being parsed, which
+// determines the operators and keys that are allowed.
+type parseCtx int
+
+const (
+	ctxTop parseCtx = iota
+	ctxSh
+	ctxWsh
+	ctxTap
+)
+
+// tapscriptOps are the operators allowed in Taproot leaves.
+var tapscriptOps = map[string]bool{
+	"pk":            true,
+	"pkh":           true,
+	"multi_a":       true,
+	"sortedmulti_a": true,
 }
 
-func parseScriptR(s, path string, topLevel bool) (ScriptExpr, error) {
-	op, args, err := splitOpAndArgs(s)
+// checkArgs verifies the number of arguments of a call node.
+func checkArgs(n *node, min, max int) error {
+	if len(n.args) < min || len(n.args) > max {
+		if min == max {
+			return errorAt(n.pos, "%s() takes exactly %d argument(s), got %d", n.name, min, len(n.args))
+		}
+		return errorAt(n.pos, "%s() takes between %d and %d arguments, got %d", n.name, min, max, len(n.args))
+	}
+	return nil
+}
+
+// wordArg returns the value of a bare argument.
+func wordArg(n *node, what string) (string, error) {
+	if n.kind != nodeWord {
+		return "", errorAt(n.pos, "expected %s", what)
+	}
+	return n.name, nil
+}
+
+// keyArg returns a key argument with path applied to it.
+func keyArg(n *node, path string) (string, error) {
+	key, err := wordArg(n, "key")
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	if IsMultipath(key) {
+		return "", errorAt(n.pos, "multipath keys must be expanded with ExpandMultipath")
+	}
+
+	key, err = applyPath(key, path)
+	if err != nil {
+		return "", errorAt(n.pos, "%v", err)
+	}
+	return key, nil
+}
+
+// compile converts a syntax tree node into a ScriptExpr.
+func compile(n *node, path string, ctx parseCtx) (ScriptExpr, error) {
+	if n.kind != nodeCall {
+		return nil, errorAt(n.pos, "expected script expression")
+	}
+
+	op := n.name
+	if (ctx == ctxWsh || ctx == ctxTap) && isMiniscript(op) {
+		return compileMiniscript(n, path, ctx)
+	}
+
+	if ctx == ctxTap && !tapscriptOps[op] {
+		return nil, errorAt(n.pos, "%s() is not allowed in tapscript", op)
 	}
 
 	switch op {
-	case "sh":
-		if !topLevel {
-			return nil, errors.New("sh must be a top-level expression")
+	case "sh", "wsh":
+		if op == "sh" && ctx != ctxTop {
+			return nil, errorAt(n.pos, "sh must be a top-level expression")
+		}
+		if op == "wsh" && ctx == ctxWsh {
+			return nil, errorAt(n.pos, "wsh must be a top-level expression or inside sh")
 		}
 
-		script, err := parseScriptR(args, path, false)
-		if err != nil {
+		if err := checkArgs(n, 1, 1); err != nil {
 			return nil, err
 		}
 
-		return Sh(script), nil
-	case "wsh":
-		script, err := parseScriptR(args, path, false)
+		if op == "sh" {
+			script, err := compile(n.args[0], path, ctxSh)
+			if err != nil {
+				return nil, err
+			}
+			return Sh(script), nil
+		}
+
+		script, err := compile(n.args[0], path, ctxWsh)
 		if err != nil {
 			return nil, err
 		}
-
 		return Wsh(script), nil
-	case "pkh":
-		der, err := deriveIfXpub(args, path)
-		if err != nil {
+	case "pk", "pkh", "wpkh", "combo":
+		if op == "combo" && ctx != ctxTop {
+			return nil, errorAt(n.pos, "combo() must be a top-level expression")
+		}
+		if op == "wpkh" && ctx != ctxTop && ctx != ctxSh {
+			return nil, errorAt(n.pos, "wpkh() must be a top-level expression or inside sh()")
+		}
+
+		if err := checkArgs(n, 1, 1); err != nil {
 			return nil, err
 		}
 
-		return Pkh(der), nil
-	case "wpkh":
-		der, err := deriveIfXpub(args, path)
+		key, err := keyArg(n.args[0], path)
 		if err != nil {
 			return nil, err
 		}
 
-		return Wpkh(der), nil
+		switch op {
+		case "pk":
+			return Pk(key), nil
+		case "pkh":
+			return Pkh(key), nil
+		case "wpkh":
+			return Wpkh(key), nil
+		}
+		return Combo(key), nil
+	case "addr", "raw":
+		if ctx != ctxTop {
+			return nil, errorAt(n.pos, "%s() must be a top-level expression", op)
+		}
+
+		if err := checkArgs(n, 1, 1); err != nil {
+			return nil, err
+		}
+
+		if op == "addr" {
+			addr, err := wordArg(n.args[0], "address")
+			if err != nil {
+				return nil, err
+			}
+			return Addr(addr), nil
+		}
+
+		hex, err := wordArg(n.args[0], "hex script")
+		if err != nil {
+			return nil, err
+		}
+		return Raw(hex), nil
 	case "multi", "sortedmulti":
-		n, keys, err := parseMultiArgs(args, path)
+		m, keys, err := compileMulti(n, path, maxMultisigKeys)
 		if err != nil {
 			return nil, err
 		}
 
 		if op == "multi" {
-			return Multi(n, keys...), nil
+			return Multi(m, keys...), nil
+		}
+		return Sortedmulti(m, keys...), nil
+	case "multi_a", "sortedmulti_a":
+		if ctx != ctxTap {
+			return nil, errorAt(n.pos, "%s() is only allowed in tr() leaves", op)
+		}
+
+		m, keys, err := compileMulti(n, path, maxMultiAKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "multi_a" {
+			return MultiA(m, keys...), nil
 		}
-		return Sortedmulti(n, keys...), nil
+		return SortedmultiA(m, keys...), nil
 	case "tr":
-		if !topLevel {
-			return nil, errors.New("tr() must be a top-level expression")
+		if ctx != ctxTop {
+			return nil, errorAt(n.pos, "tr() must be a top-level expression")
+		}
+
+		if err := checkArgs(n, 1, 2); err != nil {
+			return nil, err
+		}
+
+		key, err := keyArg(n.args[0], path)
+		if err != nil {
+			return nil, err
 		}
 
-		var key, tree string
-		split := strings.Split(args, ",")
-		switch len(split) {
-		case 1:
-			key = args
-		case 2:
-			key = split[0]
-			tree = split[1]
-		default:
-			return nil, errors.New("too many arguments for tr()")
+		var tree Tree
+		if len(n.args) == 2 {
+			tree, err = compileTree(n.args[1], path, 0)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		return Tr(key, tree), nil
 	}
 
-	return nil, fmt.Errorf("invalid op '%s'", op)
+	return nil, errorAt(n.pos, "invalid op '%s'", op)
 }
 
-// parseMultiArgs parsers a string with the form `N,<key1,key2...keyM>`
-func parseMultiArgs(args, path string) (int, []string, error) {
-	split := strings.Split(args, ",")
-	if len(split) < 2 {
-		return 0, nil, fmt.Errorf("invalid multi() argument")
+// compileMulti returns the threshold and keys of a multisig expression with
+// at most max keys.
+func compileMulti(n *node, path string, max int) (int, []string, error) {
+	if len(n.args) < 2 {
+		return 0, nil, errorAt(n.pos, "%s() requires a threshold and at least one key", n.name)
 	}
 
-	n, err := strconv.Atoi(split[0])
+	arg, err := wordArg(n.args[0], "threshold")
 	if err != nil {
 		return 0, nil, err
 	}
 
-	var keys []string
-	for _, key := range split[1:] {
-		der, err := deriveIfXpub(key, path)
+	m, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, nil, errorAt(n.args[0].pos, "invalid threshold '%s'", arg)
+	}
+
+	if err := checkThreshold(n.name, m, len(n.args)-1, max); err != nil {
+		return 0, nil, errorAt(n.pos, "%s", err)
+	}
+
+	keys := make([]string, len(n.args)-1)
+	for i, arg := range n.args[1:] {
+		keys[i], err = keyArg(arg, path)
 		if err != nil {
 			return 0, nil, err
 		}
-		keys = append(keys, der)
 	}
 
-	return n, keys, nil
+	return m, keys, nil
+}
+
+// compileTree converts a Taproot script tree node, either a leaf script or a
+// `{A,B}` branch.
+func compileTree(n *node, path string, depth int) (Tree, error) {
+	if n.kind != nodeTree {
+		script, err := compile(n, path, ctxTap)
+		if err != nil {
+			return nil, err
+		}
+		return Leaf(script), nil
+	}
+
+	if depth >= maxTapTreeDepth {
+		return nil, errorAt(n.pos, "taproot tree exceeds maximum depth")
+	}
+
+	left, err := compileTree(n.args[0], path, depth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := compileTree(n.args[1], path, depth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return Branch(left, right), nil
+}
+
+// ParseExpr parses a descriptor into an expression without evaluating it.
+func ParseExpr(s string) (ScriptExpr, error) {
+	return parseScript(s, "")
 }
 
 func Parse(s string) (*Script, error) {
@@ -169,3 +522,17 @@ func ParseWithPath(s string, path string) (*Script, error) {
 	}
 	return script.Eval()
 }
+
+// ParseAll parses a descriptor returning all of its output scripts, see
+// EvalAll.
+func ParseAll(s string) ([]*Script, error) {
+	return ParseAllWithPath(s, "")
+}
+
+func ParseAllWithPath(s string, path string) ([]*Script, error) {
+	script, err := parseScript(s, path)
+	if err != nil {
+		return nil, err
+	}
+	return EvalAll(script)
+}
@@ -1,6 +1,9 @@
 package script_test
 
 import (
+	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
 
 	. "github.com/qustavo/go-wallet/script"
@@ -64,7 +67,7 @@ func TestScriptParser(t *testing.T) {
 		{
 			name:         "P2TR",
 			script:       "tr(03aaeb52dd7494c361049de67cc680e83ebcbbbdbeb13637d92cd845f70308af5e)",
-			expectedAddr: "<not implemented>",
+			expectedAddr: "bc1plguuppjuw5uk2rpyjnnzvwsuvy5ctswns9fsvhrvn4qt04ns4nmscf9eqf",
 		},
 	}
 
@@ -81,3 +84,339 @@ func TestScriptParser(t *testing.T) {
 		})
 	}
 }
+
+func TestTaprootBIP86(t *testing.T) {
+	// Test vectors from BIP86, derived from the BIP39 mnemonic seed:
+	// `abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about`
+	const xpub = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+
+	testCases := []struct {
+		name           string
+		script         string
+		path           string
+		expectedScript string
+		expectedAddr   string
+	}{
+		{
+			name:           "first receiving address",
+			script:         "tr([73c5da0a/86'/0'/0']" + xpub + "/0/*)",
+			path:           "m/0",
+			expectedScript: "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+			expectedAddr:   "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		},
+		{
+			name:           "second receiving address",
+			script:         "tr([73c5da0a/86'/0'/0']" + xpub + "/0/*)",
+			path:           "m/1",
+			expectedScript: "5120a82f29944d65b86ae6b5e5cc75e294ead6c59391a1edc5e016e3498c67fc7bbb",
+			expectedAddr:   "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh",
+		},
+		{
+			name:           "first change address",
+			script:         "tr([73c5da0a/86'/0'/0']" + xpub + "/1/*)",
+			path:           "m/0",
+			expectedScript: "5120882d74e5d0572d5a816cef0041a96b6c1de832f6f9676d9605c44d5e9a97d3dc",
+			expectedAddr:   "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7",
+		},
+		{
+			name:           "x-only internal key",
+			script:         "tr(cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115)",
+			expectedScript: "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+			expectedAddr:   "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := ParseWithPath(test.script, test.path)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedScript, hex.EncodeToString(script.Bytes()))
+			require.Equal(t, test.expectedAddr, script.Address(Mainnet))
+		})
+	}
+}
+
+func TestTaprootNetworks(t *testing.T) {
+	script, err := Parse("tr(cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115)")
+	require.NoError(t, err)
+
+	for net, prefix := range map[*NetParams]string{
+		Mainnet: "bc1p",
+		Testnet: "tb1p",
+		Signet:  "tb1p",
+		Regtest: "bcrt1p",
+	} {
+		require.True(t, strings.HasPrefix(script.Address(net), prefix))
+	}
+}
+
+func TestP2PK(t *testing.T) {
+	testCases := []struct {
+		name           string
+		script         string
+		path           string
+		expectedScript string
+		expectedAddr   string
+	}{
+		{
+			name:           "P2PK",
+			script:         "pk(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+			expectedScript: "210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac",
+		},
+		{
+			name:           "P2PK-XPub",
+			script:         "pk(zpub6u4KbU8TSgNuZSxzv7HaGq5Tk361gMHdZxnM4UYuwzg5CMLcNytzhobitV4Zq6vWtWHpG9QijsigkxAzXvQWyLRfLq1L7VxPP1tky1hPfD4/*)",
+			path:           "m/0",
+			expectedScript: "210330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3cac",
+		},
+		{
+			name:           "P2SH-P2PK",
+			script:         "sh(pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))",
+			expectedScript: "a9141857af51a5e516552b3086430fd8ce55f7c1a52487",
+			expectedAddr:   "33ujBbb4DuSCh4kn6tYthaeyP37SgBipug",
+		},
+		{
+			name:           "P2WSH-P2PK",
+			script:         "wsh(pk(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798))",
+			expectedScript: "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+			expectedAddr:   "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := ParseWithPath(test.script, test.path)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedScript, hex.EncodeToString(script.Bytes()))
+			require.Equal(t, test.expectedAddr, script.Address(Mainnet))
+		})
+	}
+}
+
+func TestCombo(t *testing.T) {
+	testCases := []struct {
+		name          string
+		script        string
+		expectedAddrs []string
+	}{
+		{
+			name:   "compressed",
+			script: "combo(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+			expectedAddrs: []string{
+				"", // P2PK
+				"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+				"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+				"3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+			},
+		},
+		{
+			name:   "uncompressed",
+			script: "combo(0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8)",
+			expectedAddrs: []string{
+				"", // P2PK
+				"1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			scripts, err := ParseAll(test.script)
+			require.NoError(t, err)
+
+			var addrs []string
+			for _, script := range scripts {
+				addrs = append(addrs, script.Address(Mainnet))
+			}
+			require.Equal(t, test.expectedAddrs, addrs)
+		})
+	}
+
+	_, err := Parse("sh(combo(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798))")
+	require.Error(t, err)
+}
+
+func TestAddrAndRaw(t *testing.T) {
+	testCases := []struct {
+		name           string
+		script         string
+		expectedScript string
+		expectedAddr   string
+	}{
+		{
+			name:           "addr-P2PKH",
+			script:         "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)",
+			expectedScript: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+			expectedAddr:   "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		},
+		{
+			name:           "addr-P2TR",
+			script:         "addr(bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0)",
+			expectedScript: "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			expectedAddr:   "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+		},
+		{
+			name:           "addr-testnet",
+			script:         "addr(tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx)",
+			expectedScript: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+			expectedAddr:   "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		},
+		{
+			name:           "raw-P2SH",
+			script:         "raw(a914bcfeb728b584253d5f3f70bcb780e9ef218a68f487)",
+			expectedScript: "a914bcfeb728b584253d5f3f70bcb780e9ef218a68f487",
+			expectedAddr:   "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+		},
+		{
+			name:           "raw-nonstandard",
+			script:         "raw(6a0568656c6c6f)",
+			expectedScript: "6a0568656c6c6f",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := Parse(test.script)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedScript, hex.EncodeToString(script.Bytes()))
+			require.Equal(t, test.expectedAddr, script.Address(Mainnet))
+		})
+	}
+
+	for _, desc := range []string{
+		"addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMX)",
+		"raw(zz)",
+		"sh(raw(51))",
+		"wsh(addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH))",
+	} {
+		_, err := Parse(desc)
+		require.Error(t, err, desc)
+	}
+}
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name:     "P2PKH-origin",
+			script:   "pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)",
+			expected: "pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)#ml40v0wf",
+		},
+		{
+			name:     "P2SH-P2WPKH",
+			script:   "sh(wpkh(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556))#qkrrc7je",
+			expected: "sh(wpkh(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556))#qkrrc7je",
+		},
+		{
+			name: "P2WSH-Sortedmulti",
+			script: `
+				wsh(sortedmulti(2,
+					03a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff,
+					0375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c,
+					03c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f880
+				))
+			`,
+			expected: "wsh(sortedmulti(2,03a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff,0375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c,03c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f880))",
+		},
+		{
+			name:     "P2TR-tree",
+			script:   "tr(187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27,{pk(d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8),pkh(02d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8)})",
+			expected: "tr(187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27,{pk(d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8),pkh(02d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8)})",
+		},
+		{
+			name:     "combo",
+			script:   "combo(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+			expected: "combo(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+		},
+		{
+			name:     "raw",
+			script:   "raw(deadbeef)",
+			expected: "raw(deadbeef)#89f8spxm",
+		},
+		{
+			name:     "addr",
+			script:   "addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)",
+			expected: "addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := ParseExpr(test.script)
+			require.NoError(t, err)
+
+			desc := expr.String()
+			if !strings.Contains(test.expected, "#") {
+				test.expected, err = AddChecksum(test.expected)
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.expected, desc)
+
+			// Parsing the output again must be stable.
+			reparsed, err := ParseExpr(desc)
+			require.NoError(t, err)
+			require.Equal(t, desc, reparsed.String())
+
+			eval, err := expr.Eval()
+			require.NoError(t, err)
+			reeval, err := reparsed.Eval()
+			require.NoError(t, err)
+			require.Equal(t, eval.Bytes(), reeval.Bytes())
+		})
+	}
+}
+
+func TestFluentAPIString(t *testing.T) {
+	expr := Wsh(Sortedmulti(2,
+		"0375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c",
+		"03a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff",
+		"03c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f880",
+	))
+
+	script, err := Parse(expr.String())
+	require.NoError(t, err)
+	require.Equal(t, "bc1qwqdg6squsna38e46795at95yu9atm8azzmyvckulcc7kytlcckxswvvzej", script.Address(Mainnet))
+}
+
+func TestParseErrors(t *testing.T) {
+	const key = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+	testCases := []struct {
+		script         string
+		expectedOffset int
+		expectedMsg    string
+	}{
+		{"wpkh(" + key, 71, "unexpected end of input, expected ',' or ')'"},
+		{"wpkh(" + key + "))", 72, "unexpected ')', expected end of input"},
+		{"wpkh(,)", 5, "unexpected ',', expected expression"},
+		{"  sh(sh(pk(" + key + ")))", 5, "sh must be a top-level expression"},
+		{"wsh(wsh(pk(" + key + ")))", 4, "wsh must be a top-level expression or inside sh"},
+		{"wsh(wpkh(" + key + "))", 4, "wpkh() must be a top-level expression or inside sh()"},
+		{"sh(wsh(wpkh(" + key + ")))", 7, "wpkh() must be a top-level expression or inside sh()"},
+		{"wsh(multi(x," + key + "))", 10, "invalid threshold 'x'"},
+		{"tr(" + key + ",{pk(" + key + ")})", 141, "unexpected '}', expected ','"},
+		{"tr(" + key + ",{pk(" + key + "),wpkh(" + key + ")})", 142, "wpkh() is not allowed in tapscript"},
+		{"pkh(" + key + "," + key + ")", 0, "pkh() takes exactly 1 argument(s), got 2"},
+		{"pkh(pk(" + key + "))", 4, "expected key"},
+		{"foo(" + key + ")", 0, "invalid op 'foo'"},
+		{key, 0, "expected script expression"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.script, func(t *testing.T) {
+			_, err := Parse(test.script)
+			require.Error(t, err)
+
+			var parseErr *ParseError
+			require.True(t, errors.As(err, &parseErr), err)
+			require.Equal(t, test.expectedOffset, parseErr.Offset)
+			require.Equal(t, test.expectedMsg, parseErr.Msg)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// HardenedKeyStart is the index of the first hardened child key.
+const HardenedKeyStart = hdkeychain.HardenedKeyStart
+
+// DerivationPath is a BIP32 derivation path, each level is a child index
+// where indexes from HardenedKeyStart are hardened.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a path like `m/44'/0'/0`, hardened levels can be
+// marked with `'`, `h` or `H`.
+func ParseDerivationPath(s string) (DerivationPath, error) {
+	if s == "m" {
+		return DerivationPath{}, nil
+	}
+	if !strings.HasPrefix(s, "m/") {
+		return nil, fmt.Errorf("path: invalid prefix in '%s', expected 'm/'", s)
+	}
+
+	levels := strings.Split(strings.TrimPrefix(s, "m/"), "/")
+	path := make(DerivationPath, len(levels))
+	for i, level := range levels {
+		index, err := parsePathLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		path[i] = index
+	}
+
+	return path, nil
+}
+
+// parsePathLevel parses a single path level, which must be below 2^31 with
+// an optional hardened marker.
+func parsePathLevel(level string) (uint32, error) {
+	index, hardened := level, false
+	if isHardened(level) {
+		index, hardened = level[:len(level)-1], true
+	}
+
+	// ParseUint rejects signs, so only plain numbers are accepted.
+	i, err := strconv.ParseUint(index, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("path: invalid level '%s'", level)
+	}
+
+	if hardened {
+		return uint32(i) + HardenedKeyStart, nil
+	}
+	return uint32(i), nil
+}
+
+// isHardened returns if a path level is hardened.
+func isHardened(level string) bool {
+	return strings.HasSuffix(level, "'") ||
+		strings.HasSuffix(level, "h") ||
+		strings.HasSuffix(level, "H")
+}
+
+func (p DerivationPath) String() string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, i := range p {
+		if i >= HardenedKeyStart {
+			fmt.Fprintf(&sb, "/%d'", i-HardenedKeyStart)
+		} else {
+			fmt.Fprintf(&sb, "/%d", i)
+		}
+	}
+	return sb.String()
+}
+
+// levels returns the path without the `m` prefix, e.g. `/44'/0'/0`.
+func (p DerivationPath) levels() string {
+	return strings.TrimPrefix(p.String(), "m")
+}
+
+// wildcard is the final `*` of a ranged key expression.
+type wildcard int
+
+const (
+	wildcardNone wildcard = iota
+	// wildcardUnhardened ranges over the non hardened children, `/*`.
+	wildcardUnhardened
+	// wildcardHardened ranges over the hardened children, `/*'`.
+	wildcardHardened
+)
+
+func (w wildcard) String() string {
+	switch w {
+	case wildcardUnhardened:
+		return "/*"
+	case wildcardHardened:
+		return "/*'"
+	}
+	return ""
+}
+
+// child returns the child index i of the wildcard.
+func (w wildcard) child(i uint32) uint32 {
+	if w == wildcardHardened {
+		return i + HardenedKeyStart
+	}
+	return i
+}
+
+// parseKeyPath parses the `/1/2/*` derivation path following an extended key,
+// the wildcard, if any, must be the last level.
+func parseKeyPath(s string) (DerivationPath, wildcard, error) {
+	if s == "" {
+		return DerivationPath{}, wildcardNone, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, wildcardNone, fmt.Errorf("path: invalid key path '%s'", s)
+	}
+
+	w := wildcardNone
+	for _, suffix := range []string{"/*'", "/*h", "/*H", "/*"} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSuffix(s, suffix)
+			w = wildcardUnhardened
+			if suffix != "/*" {
+				w = wildcardHardened
+			}
+			break
+		}
+	}
+
+	if s == "" {
+		return DerivationPath{}, w, nil
+	}
+
+	path, err := ParseDerivationPath("m" + s)
+	if err != nil {
+		return nil, wildcardNone, err
+	}
+	return path, w, nil
+}
+
+// deriveKey derives the path from an extended key. Hardened levels can only
+// be derived from private keys.
+func deriveKey(key *hdkeychain.ExtendedKey, path DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	for _, i := range path {
+		if i >= HardenedKeyStart && !key.IsPrivate() {
+			return nil, fmt.Errorf("can't derive hardened level %d' from a public key", i-HardenedKeyStart)
+		}
+
+		var err error
+		if key, err = key.Derive(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
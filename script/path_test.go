@@ -0,0 +1,132 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivationPath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected DerivationPath
+		str      string
+	}{
+		{"m", DerivationPath{}, "m"},
+		{"m/0", DerivationPath{0}, "m/0"},
+		{"m/44'/0h/0H/1/2147483647", DerivationPath{44 + HardenedKeyStart, HardenedKeyStart, HardenedKeyStart, 1, 2147483647}, "m/44'/0'/0'/1/2147483647"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.path, func(t *testing.T) {
+			path, err := ParseDerivationPath(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, path)
+			assert.Equal(t, test.str, path.String())
+		})
+	}
+
+	for _, path := range []string{
+		"",
+		"0/1",
+		"m/",
+		"m//1",
+		"m/1/",
+		"m/-1",
+		"m/+1",
+		"m/2147483648",
+		"m/4294967296",
+		"m/1''",
+		"m/*",
+		"m/1x",
+	} {
+		_, err := ParseDerivationPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestKeyPath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected DerivationPath
+		wildcard wildcard
+	}{
+		{"", DerivationPath{}, wildcardNone},
+		{"/1/2", DerivationPath{1, 2}, wildcardNone},
+		{"/*", DerivationPath{}, wildcardUnhardened},
+		{"/0'/*", DerivationPath{HardenedKeyStart}, wildcardUnhardened},
+		{"/0/*h", DerivationPath{0}, wildcardHardened},
+	}
+
+	for _, test := range testCases {
+		path, w, err := parseKeyPath(test.path)
+		require.NoError(t, err, test.path)
+		assert.Equal(t, test.expected, path, test.path)
+		assert.Equal(t, test.wildcard, w, test.path)
+	}
+
+	for _, path := range []string{"1", "/*/1", "/**", "/1/", "/*''"} {
+		_, _, err := parseKeyPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestHardenedDerivation(t *testing.T) {
+	const (
+		// BIP32 test vector 1.
+		xprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+		xpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+	)
+
+	t.Run("hardened wildcard", func(t *testing.T) {
+		ranged, err := NewXPub(xprv + "/0/*'")
+		require.NoError(t, err)
+		child, err := ranged.Child(2)
+		require.NoError(t, err)
+
+		expected, _, err := evalKey(xprv + "/0/2'")
+		require.NoError(t, err)
+		assert.Equal(t, expected.String(), child.String())
+
+		_, err = ranged.Child(HardenedKeyStart)
+		assert.Error(t, err)
+	})
+
+	t.Run("public keys", func(t *testing.T) {
+		_, err := NewXPub(xpub + "/0'")
+		assert.Error(t, err)
+
+		ranged, err := NewXPub(xpub + "/0/*'")
+		require.NoError(t, err)
+		_, err = ranged.Child(0)
+		assert.Error(t, err)
+
+		for _, key := range []string{xpub + "/1h", xpub + "/0/*'"} {
+			_, _, err := evalKey(key)
+			assert.Error(t, err, key)
+		}
+
+		_, err = ParseWithPath("wpkh("+xpub+"/0/*')", "m/1")
+		assert.Error(t, err)
+	})
+
+	t.Run("wallet path", func(t *testing.T) {
+		for _, test := range []struct{ key, path, expected string }{
+			{xpub + "/0/*", "m/1", xpub + "/0/1"},
+			{xpub + "/0/*h", "m/1", xpub + "/0/1'"},
+			{xprv + "/*'", "m/1/2'", xprv + "/1/2'"},
+			{xpub, "m/3/4", xpub + "/3/4"},
+			{xpub + "/*", "m", xpub + "/*"},
+		} {
+			key, err := applyPath(test.key, test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, key)
+		}
+
+		for _, path := range []string{"0/1", "m/-1", "m/2147483648"} {
+			_, err := applyPath(xpub+"/*", path)
+			assert.Error(t, err, path)
+		}
+	})
+}
@@ -0,0 +1,557 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Policies are a high level language to describe spending conditions which
+// gets compiled into miniscript, e.g. `or(99@pk(A),and(pk(B),older(1000)))`
+// is spent by A most of the time, or by B after 1000 blocks. The compiler
+// looks for the miniscript minimizing the script size plus the expected
+// witness size, where the `N@` weights of or() are the relative
+// probabilities of each branch.
+
+// Witness element sizes, including their length prefix.
+const (
+	sigWitnessSize      = 1 + 72
+	pubKeyWitnessSize   = 1 + 33
+	preimageWitnessSize = 1 + 32
+	emptyWitnessSize    = 1
+	oneWitnessSize      = 1 + 1
+)
+
+type policyKind int
+
+const (
+	policyKey policyKind = iota
+	policyOlder
+	policyAfter
+	policyHash
+	policyAnd
+	policyOr
+	policyThresh
+)
+
+// policy is a node of a parsed policy.
+type policy struct {
+	kind policyKind
+	key  string
+	// k is the threshold of thresh() or the timelock of older() and after().
+	k uint32
+	// hash is the hash fragment of policyHash policies.
+	hash *msNode
+	subs []*policy
+	// weights are the relative probabilities of the or() branches.
+	weights []int
+
+	// compiled are the memoized candidates by their probabilities.
+	compiled map[[2]float64][]*candidate
+}
+
+// CompiledPolicy is a policy compiled into miniscript for P2WSH.
+type CompiledPolicy struct {
+	Miniscript ScriptExpr
+	// ScriptSize is the size of the witness script.
+	ScriptSize int
+	// WitnessSize is the expected size of the witness satisfying the script,
+	// weighting or() branches by their probabilities. It doesn't include the
+	// witness script itself.
+	WitnessSize float64
+}
+
+// Descriptor returns the wsh() descriptor of the compiled policy.
+func (c *CompiledPolicy) Descriptor() string {
+	return Wsh(c.Miniscript).String()
+}
+
+// CompilePolicy compiles a policy into the miniscript with the lowest
+// expected spending cost. Policies are made of:
+//
+//	pk(KEY), older(N), after(N), sha256(H), hash256(H), ripemd160(H),
+//	hash160(H), and(X,Y), or([N@]X,[M@]Y) and thresh(k,X,Y,...)
+func CompilePolicy(s string) (*CompiledPolicy, error) {
+	n, err := parseDescriptor(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parsePolicy(n)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *candidate
+	for _, c := range p.compile(1, 0) {
+		ms := &miniscript{root: c.ms}
+		if ms.check(msP2WSH) != nil {
+			continue
+		}
+		if best == nil || c.cost(1, 0) < best.cost(1, 0) {
+			best = c
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("policy: no sane miniscript found for %s, policies must require a signature and not mix timelocks", p)
+	}
+
+	// Keys are kept as text while compiling, evaluating the result validates
+	// them.
+	ms := &miniscript{root: best.ms}
+	if _, err := ms.Eval(); err != nil {
+		return nil, err
+	}
+
+	return &CompiledPolicy{
+		Miniscript:  ms,
+		ScriptSize:  best.size,
+		WitnessSize: best.sat,
+	}, nil
+}
+
+func parsePolicy(n *node) (*policy, error) {
+	if n.kind != nodeCall {
+		return nil, errorAt(n.pos, "expected policy expression")
+	}
+
+	switch n.name {
+	case "pk":
+		if err := checkArgs(n, 1, 1); err != nil {
+			return nil, err
+		}
+
+		key, err := keyArg(n.args[0], "")
+		if err != nil {
+			return nil, err
+		}
+		return &policy{kind: policyKey, key: key}, nil
+	case "older", "after", "sha256", "hash256", "ripemd160", "hash160":
+		// These are valid miniscript fragments as well.
+		ms, err := parseMiniscriptFragment(n, n.name, "")
+		if err != nil {
+			return nil, err
+		}
+
+		switch ms.frag {
+		case msOlder:
+			return &policy{kind: policyOlder, k: ms.k}, nil
+		case msAfter:
+			return &policy{kind: policyAfter, k: ms.k}, nil
+		}
+		return &policy{kind: policyHash, hash: ms}, nil
+	case "and", "or":
+		if err := checkArgs(n, 2, 2); err != nil {
+			return nil, err
+		}
+
+		p := &policy{kind: policyAnd}
+		if n.name == "or" {
+			p.kind = policyOr
+		}
+
+		for _, arg := range n.args {
+			weight := 1
+			if i := strings.IndexByte(arg.name, '@'); i >= 0 {
+				if p.kind != policyOr {
+					return nil, errorAt(arg.pos, "weights are only allowed in or()")
+				}
+
+				w, err := strconv.Atoi(arg.name[:i])
+				if err != nil || w < 1 {
+					return nil, errorAt(arg.pos, "invalid weight '%s'", arg.name[:i])
+				}
+				weight = w
+				arg = &node{kind: arg.kind, name: arg.name[i+1:], args: arg.args, pos: arg.pos}
+			}
+
+			sub, err := parsePolicy(arg)
+			if err != nil {
+				return nil, err
+			}
+			p.subs = append(p.subs, sub)
+			p.weights = append(p.weights, weight)
+		}
+		return p, nil
+	case "thresh":
+		if len(n.args) < 2 {
+			return nil, errorAt(n.pos, "thresh() requires a threshold and at least one policy")
+		}
+
+		arg, err := wordArg(n.args[0], "threshold")
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil || k < 1 || int(k) > len(n.args)-1 {
+			return nil, errorAt(n.args[0].pos, "invalid threshold '%s'", arg)
+		}
+
+		p := &policy{kind: policyThresh, k: uint32(k)}
+		for _, arg := range n.args[1:] {
+			sub, err := parsePolicy(arg)
+			if err != nil {
+				return nil, err
+			}
+			p.subs = append(p.subs, sub)
+		}
+		return p, nil
+	}
+
+	return nil, errorAt(n.pos, "invalid policy '%s'", n.name)
+}
+
+// candidate is a possible compilation of a policy.
+type candidate struct {
+	ms   *msNode
+	typ  msType
+	size int
+	// sat and dsat are the expected sizes of the satisfaction and
+	// dissatisfaction witnesses, +Inf if there's none.
+	sat, dsat float64
+}
+
+// cost is the expected cost of spending the candidate when it has to be
+// satisfied with probability pSat and dissatisfied with probability pDsat.
+func (c *candidate) cost(pSat, pDsat float64) float64 {
+	cost := float64(c.size) + pSat*c.sat
+	if pDsat > 0 {
+		cost += pDsat * c.dsat
+	}
+	return cost
+}
+
+// newCandidate returns the candidate for the fragment ms made of subs, or
+// nil if its type is invalid.
+func newCandidate(ms *msNode, subs []*candidate, sat, dsat float64) *candidate {
+	types := make([]msType, len(subs))
+	ms.subs = make([]*msNode, len(subs))
+	size := 0
+	for i, sub := range subs {
+		types[i] = sub.typ
+		ms.subs[i] = sub.ms
+		size += sub.size
+	}
+
+	t := ms.computeType(msP2WSH, types)
+	basic := 0
+	for _, b := range "BVKW" {
+		if t.has(string(b)) {
+			basic++
+		}
+	}
+	if basic != 1 {
+		return nil
+	}
+
+	return &candidate{
+		ms:   ms,
+		typ:  t,
+		size: size + ms.scriptOverhead(types),
+		sat:  sat,
+		dsat: dsat,
+	}
+}
+
+// scriptOverhead returns the size of the fragment script excluding the
+// scripts of its subexpressions.
+func (n *msNode) scriptOverhead(subs []msType) int {
+	switch n.frag {
+	case msJust0, msJust1:
+		return 1
+	case msPkK:
+		return 1 + 33
+	case msPkH:
+		return 3 + 20 + 1
+	case msOlder, msAfter:
+		return len(pushNumber(int64(n.k))) + 1
+	case msSha256, msHash256, msRipemd160, msHash160:
+		return 6 + 1 + len(n.hash)
+	case msWrapA:
+		return 2
+	case msWrapS, msWrapC, msWrapN, msAndB, msOrB:
+		return 1
+	case msWrapD, msOrD, msOrI, msAndOr:
+		return 3
+	case msWrapV:
+		if subs[0].has("x") {
+			return 1
+		}
+		return 0
+	case msWrapJ:
+		return 4
+	case msOrC:
+		return 2
+	case msThresh:
+		return len(subs) - 1 + len(pushNumber(int64(n.k))) + 1
+	case msMulti:
+		return len(pushNumber(int64(n.k))) + (1+33)*len(n.keys) + len(pushNumber(int64(len(n.keys)))) + 1
+	}
+	return 0
+}
+
+// candidateKey are the type properties which tell candidates apart, only
+// the cheapest candidate of each combination is kept.
+var candidateKey = mst("BVKWzonduemsk")
+
+type candidates map[msType]*candidate
+
+func (cs candidates) add(c *candidate, pSat, pDsat float64) {
+	if c == nil {
+		return
+	}
+
+	key := c.typ & candidateKey
+	if old, ok := cs[key]; ok && !c.cheaper(old, pSat, pDsat) {
+		return
+	}
+	cs[key] = c
+}
+
+// cheaper returns if c costs less than other. Candidates which can't be
+// dissatisfied still compete among themselves by their satisfaction cost, as
+// some fragments never dissatisfy them.
+func (c *candidate) cheaper(other *candidate, pSat, pDsat float64) bool {
+	cost, otherCost := c.cost(pSat, pDsat), other.cost(pSat, pDsat)
+	if cost == otherCost {
+		return c.cost(pSat, 0) < other.cost(pSat, 0)
+	}
+	return cost < otherCost
+}
+
+// list returns the candidates sorted by cost, so results are deterministic.
+func (cs candidates) list(pSat, pDsat float64) []*candidate {
+	list := make([]*candidate, 0, len(cs))
+	for _, c := range cs {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].cheaper(list[j], pSat, pDsat) || list[j].cheaper(list[i], pSat, pDsat) {
+			return list[i].cheaper(list[j], pSat, pDsat)
+		}
+		return list[i].ms.String() < list[j].ms.String()
+	})
+	return list
+}
+
+// wrap adds the wrapped versions of the candidates, which turns them into
+// other types.
+func (cs candidates) wrap(pSat, pDsat float64) {
+	inf := math.Inf(1)
+	// A few rounds are enough to chain the wrappers needed to convert between
+	// any types, e.g. `sdv:`.
+	for round := 0; round < 3; round++ {
+		for _, c := range cs.list(pSat, pDsat) {
+			for _, w := range []msFragment{msWrapA, msWrapS, msWrapC, msWrapN} {
+				cs.add(newCandidate(&msNode{frag: w}, []*candidate{c}, c.sat, c.dsat), pSat, pDsat)
+			}
+			cs.add(newCandidate(&msNode{frag: msWrapD}, []*candidate{c}, c.sat+oneWitnessSize, emptyWitnessSize), pSat, pDsat)
+			cs.add(newCandidate(&msNode{frag: msWrapV}, []*candidate{c}, c.sat, inf), pSat, pDsat)
+			cs.add(newCandidate(&msNode{frag: msWrapJ}, []*candidate{c}, c.sat, emptyWitnessSize), pSat, pDsat)
+
+			// u:X, which is or_i(X,0).
+			zero := newCandidate(&msNode{frag: msJust0}, nil, inf, 0)
+			cs.add(newCandidate(&msNode{frag: msOrI}, []*candidate{c, zero}, c.sat+oneWitnessSize, emptyWitnessSize), pSat, pDsat)
+		}
+	}
+}
+
+// compile returns the candidates of the policy when it has to be satisfied
+// with probability pSat and dissatisfied with probability pDsat. Candidates
+// are memoized, as thresh() compiles its subpolicies both on their own and
+// within and() or or() chains, which would be exponential on nested
+// thresholds.
+func (p *policy) compile(pSat, pDsat float64) []*candidate {
+	// Probabilities reached through different paths might differ slightly.
+	round := func(f float64) float64 { return math.Round(f*1e12) / 1e12 }
+	key := [2]float64{round(pSat), round(pDsat)}
+	if list, ok := p.compiled[key]; ok {
+		return list
+	}
+
+	list := p.compileCandidates(pSat, pDsat)
+	if p.compiled == nil {
+		p.compiled = make(map[[2]float64][]*candidate)
+	}
+	p.compiled[key] = list
+	return list
+}
+
+func (p *policy) compileCandidates(pSat, pDsat float64) []*candidate {
+	inf := math.Inf(1)
+	cs := make(candidates)
+
+	switch p.kind {
+	case policyKey:
+		cs.add(newCandidate(&msNode{frag: msPkK, keys: []string{p.key}}, nil, sigWitnessSize, emptyWitnessSize), pSat, pDsat)
+		cs.add(newCandidate(&msNode{frag: msPkH, keys: []string{p.key}}, nil, sigWitnessSize+pubKeyWitnessSize, emptyWitnessSize+pubKeyWitnessSize), pSat, pDsat)
+	case policyOlder:
+		cs.add(newCandidate(&msNode{frag: msOlder, k: p.k}, nil, 0, inf), pSat, pDsat)
+	case policyAfter:
+		cs.add(newCandidate(&msNode{frag: msAfter, k: p.k}, nil, 0, inf), pSat, pDsat)
+	case policyHash:
+		cs.add(newCandidate(&msNode{frag: p.hash.frag, hash: p.hash.hash}, nil, preimageWitnessSize, preimageWitnessSize), pSat, pDsat)
+	case policyAnd:
+		xs := p.subs[0].compile(pSat, pDsat)
+		ys := p.subs[1].compile(pSat, pDsat)
+		for _, pair := range [][2][]*candidate{{xs, ys}, {ys, xs}} {
+			for _, x := range pair[0] {
+				for _, y := range pair[1] {
+					cs.addAnd(x, y, pSat, pDsat)
+				}
+			}
+		}
+	case policyOr:
+		total := float64(p.weights[0] + p.weights[1])
+		px, py := float64(p.weights[0])/total, float64(p.weights[1])/total
+
+		xs := p.subs[0].compile(pSat*px, pDsat+pSat*py)
+		ys := p.subs[1].compile(pSat*py, pDsat+pSat*px)
+		for _, x := range xs {
+			for _, y := range ys {
+				cs.addOr(x, y, px, py, pSat, pDsat)
+				cs.addOr(y, x, py, px, pSat, pDsat)
+			}
+		}
+	case policyThresh:
+		p.compileThresh(cs, pSat, pDsat)
+	}
+
+	cs.wrap(pSat, pDsat)
+	return cs.list(pSat, pDsat)
+}
+
+func (cs candidates) addAnd(x, y *candidate, pSat, pDsat float64) {
+	inf := math.Inf(1)
+	sat := x.sat + y.sat
+	zero := newCandidate(&msNode{frag: msJust0}, nil, inf, 0)
+
+	cs.add(newCandidate(&msNode{frag: msAndV}, []*candidate{x, y}, sat, inf), pSat, pDsat)
+	cs.add(newCandidate(&msNode{frag: msAndB}, []*candidate{x, y}, sat, x.dsat+y.dsat), pSat, pDsat)
+	cs.add(newCandidate(&msNode{frag: msAndOr}, []*candidate{x, y, zero}, sat, x.dsat), pSat, pDsat)
+}
+
+// addOr adds the or() fragments of x and z, which are satisfied with
+// probabilities px and pz.
+func (cs candidates) addOr(x, z *candidate, px, pz, pSat, pDsat float64) {
+	inf := math.Inf(1)
+	dsat := x.dsat + z.dsat
+	// Satisfying z requires dissatisfying x first.
+	satD := px*x.sat + pz*(x.dsat+z.sat)
+
+	cs.add(newCandidate(&msNode{frag: msOrB}, []*candidate{x, z}, px*(x.sat+z.dsat)+pz*(x.dsat+z.sat), dsat), pSat, pDsat)
+	cs.add(newCandidate(&msNode{frag: msOrD}, []*candidate{x, z}, satD, dsat), pSat, pDsat)
+	cs.add(newCandidate(&msNode{frag: msOrC}, []*candidate{x, z}, satD, inf), pSat, pDsat)
+	cs.add(newCandidate(&msNode{frag: msOrI}, []*candidate{x, z},
+		px*(x.sat+oneWitnessSize)+pz*(z.sat+emptyWitnessSize),
+		math.Min(x.dsat+oneWitnessSize, z.dsat+emptyWitnessSize),
+	), pSat, pDsat)
+}
+
+func (p *policy) compileThresh(cs candidates, pSat, pDsat float64) {
+	n, k := len(p.subs), int(p.k)
+
+	// thresh(1,...) and thresh(n,...) are or() and and() chains.
+	if n > 1 && (k == 1 || k == n) {
+		kind := policyAnd
+		if k == 1 {
+			kind = policyOr
+		}
+
+		chain := p.subs[n-1]
+		for i := n - 2; i >= 0; i-- {
+			chain = &policy{
+				kind:    kind,
+				subs:    []*policy{p.subs[i], chain},
+				weights: []int{1, n - 1 - i},
+			}
+		}
+		for _, c := range chain.compile(pSat, pDsat) {
+			cs.add(c, pSat, pDsat)
+		}
+	}
+
+	// Thresholds of keys can be a multi().
+	var keys []string
+	for _, sub := range p.subs {
+		if sub.kind == policyKey {
+			keys = append(keys, sub.key)
+		}
+	}
+	if len(keys) == n && n <= maxMultisigKeys {
+		cs.add(newCandidate(&msNode{frag: msMulti, k: p.k, keys: keys}, nil,
+			float64(emptyWitnessSize+k*sigWitnessSize),
+			float64(emptyWitnessSize+k*emptyWitnessSize),
+		), pSat, pDsat)
+	}
+
+	// thresh() requires a Bdu first subexpression followed by Wdu ones.
+	pSub := pSat * float64(k) / float64(n)
+	pSubDsat := pDsat + pSat*float64(n-k)/float64(n)
+
+	subs := make([]*candidate, n)
+	for i, sub := range p.subs {
+		props := "Wdu"
+		if i == 0 {
+			props = "Bdu"
+		}
+		for _, c := range sub.compile(pSub, pSubDsat) {
+			if c.typ.has(props) && !math.IsInf(c.dsat, 1) {
+				subs[i] = c
+				break
+			}
+		}
+		if subs[i] == nil {
+			return
+		}
+	}
+
+	// The cheapest satisfaction satisfies the k subexpressions which cost
+	// the least over their dissatisfaction.
+	var dsat float64
+	diffs := make([]float64, n)
+	for i, sub := range subs {
+		dsat += sub.dsat
+		diffs[i] = sub.sat - sub.dsat
+	}
+	sort.Float64s(diffs)
+	sat := dsat
+	for _, diff := range diffs[:k] {
+		sat += diff
+	}
+
+	cs.add(newCandidate(&msNode{frag: msThresh, k: p.k}, subs, sat, dsat), pSat, pDsat)
+}
+
+// String returns the policy in its textual form, the one it's parsed from.
+func (p *policy) String() string {
+	switch p.kind {
+	case policyKey:
+		return "pk(" + p.key + ")"
+	case policyOlder:
+		return "older(" + strconv.FormatUint(uint64(p.k), 10) + ")"
+	case policyAfter:
+		return "after(" + strconv.FormatUint(uint64(p.k), 10) + ")"
+	case policyHash:
+		return msFragmentNames[p.hash.frag] + "(" + hex.EncodeToString(p.hash.hash) + ")"
+	}
+
+	var args []string
+	if p.kind == policyThresh {
+		args = append(args, strconv.FormatUint(uint64(p.k), 10))
+	}
+	for i, sub := range p.subs {
+		arg := sub.String()
+		if p.kind == policyOr && p.weights[i] != 1 {
+			arg = fmt.Sprintf("%d@%s", p.weights[i], arg)
+		}
+		args = append(args, arg)
+	}
+
+	name := map[policyKind]string{policyAnd: "and", policyOr: "or", policyThresh: "thresh"}[p.kind]
+	return name + "(" + strings.Join(args, ",") + ")"
+}
@@ -0,0 +1,161 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePolicy(t *testing.T) {
+	const (
+		keyA = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+		keyC = "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"
+		hash = "0101010101010101010101010101010101010101010101010101010101010101"
+	)
+	r := strings.NewReplacer("A", keyA, "B", keyB, "C", keyC, "H", hash)
+
+	testCases := []struct {
+		policy   string
+		expected string
+		witness  float64
+	}{
+		{
+			policy:   "pk(A)",
+			expected: "pk(A)",
+			witness:  73,
+		},
+		{
+			policy:   "or(99@pk(A),and(pk(B),older(1000)))",
+			expected: "or_d(pk(A),and_v(v:pkh(B),older(1000)))",
+			witness:  0.99*73 + 0.01*(1+73+34),
+		},
+		{
+			policy:   "or(pk(A),pk(B))",
+			expected: "or_b(pk(A),s:pk(B))",
+			witness:  74,
+		},
+		{
+			policy:   "thresh(2,pk(A),pk(B),pk(C))",
+			expected: "multi(2,A,B,C)",
+			witness:  1 + 2*73,
+		},
+		{
+			policy:   "and(pk(A),sha256(H))",
+			expected: "and_v(v:pk(A),sha256(H))",
+			witness:  73 + 33,
+		},
+		{
+			policy:   "and(pk(A),after(500000001))",
+			expected: "and_v(v:pk(A),after(500000001))",
+			witness:  73,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.policy, func(t *testing.T) {
+			n, err := parseDescriptor(r.Replace(test.policy))
+			require.NoError(t, err)
+			p, err := parsePolicy(n)
+			require.NoError(t, err)
+			assert.Equal(t, r.Replace(test.policy), p.String())
+
+			c, err := CompilePolicy(r.Replace(test.policy))
+			require.NoError(t, err)
+
+			assert.Equal(t, "wsh("+r.Replace(test.expected)+")", strings.Split(c.Descriptor(), "#")[0])
+			assert.InDelta(t, test.witness, c.WitnessSize, 1e-9)
+
+			// The estimated size must match the compiled script and the
+			// descriptor must be valid.
+			script, err := c.Miniscript.Eval()
+			require.NoError(t, err)
+			assert.Equal(t, len(script.Bytes()), c.ScriptSize)
+
+			expr, err := ParseExpr(c.Descriptor())
+			require.NoError(t, err)
+			assert.Equal(t, c.Descriptor(), expr.String())
+		})
+	}
+}
+
+func TestCompilePolicyWeights(t *testing.T) {
+	const (
+		keyA = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		keyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	)
+
+	// The likely branch should be the cheapest to satisfy.
+	likelyA, err := CompilePolicy("or(9@pk(" + keyA + "),and(pk(" + keyB + "),older(10)))")
+	require.NoError(t, err)
+	likelyB, err := CompilePolicy("or(pk(" + keyA + "),9@and(pk(" + keyB + "),older(10)))")
+	require.NoError(t, err)
+
+	assert.Less(t, likelyA.WitnessSize, likelyB.WitnessSize)
+}
+
+func TestCompilePolicyNestedThresh(t *testing.T) {
+	keys := []string{
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+		"02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		"02e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13",
+		"022f8bde4d1a07209355b4a7250a5c5128e88b84bddc619ab7cba8d569b240efe4",
+		"03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556",
+		"025cbdf0646e5db4eaa398f365f2ea7a0e3d419b7e0330e39ce92bddedcac4f9bc",
+		"022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01",
+		"03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe",
+		"03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7",
+		"03774ae7f858a9411e5ef4246b70c65aac5649980be5c17891bbec17895da008cb",
+		"03d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a",
+		"03f28773c2d975288bc7d1d205c3748651b075fbc6610e58cddeeddf8f19405aa8",
+	}
+
+	// Nesting thresholds used to double the compilation time at each level.
+	policy := "pk(" + keys[0] + ")"
+	for _, key := range keys[1:] {
+		policy = "thresh(1," + policy + ",pk(" + key + "))"
+	}
+
+	c, err := CompilePolicy(policy)
+	require.NoError(t, err)
+
+	script, err := c.Miniscript.Eval()
+	require.NoError(t, err)
+	assert.Equal(t, len(script.Bytes()), c.ScriptSize)
+	assert.Len(t, script.Keys(), len(keys))
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	const (
+		keyA = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		hash = "0101010101010101010101010101010101010101010101010101010101010101"
+	)
+
+	testCases := []struct {
+		name   string
+		policy string
+	}{
+		{"unknown", "foo(" + keyA + ")"},
+		{"no signature", "older(10)"},
+		{"no signature branch", "or(pk(" + keyA + "),sha256(" + hash + "))"},
+		{"weight in and", "and(2@pk(" + keyA + "),older(10))"},
+		{"invalid weight", "or(0@pk(" + keyA + "),older(10))"},
+		{"invalid threshold", "thresh(3,pk(" + keyA + "),older(10))"},
+		{"timelock mix", "and(pk(" + keyA + "),and(after(100),after(500000001)))"},
+		{"invalid key", "pk(02ff)"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := CompilePolicy(test.policy)
+			assert.Error(t, err)
+		})
+	}
+
+	// Policies without a sane miniscript are shown in the error.
+	_, err := CompilePolicy(" older(10) ")
+	assert.EqualError(t, err, "policy: no sane miniscript found for older(10), policies must require a signature and not mix timelocks")
+}
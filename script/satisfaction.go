@@ -0,0 +1,296 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// Maximum sizes of the data satisfying a script, used to estimate the size
+// of the inputs spending it.
+const (
+	// maxECDSASigSize is the size of a DER signature with high R and S
+	// values plus its sighash byte.
+	maxECDSASigSize = 72 + 1
+	// maxSchnorrSigSize is the size of a Schnorr signature with an explicit
+	// sighash byte.
+	maxSchnorrSigSize = 64 + 1
+
+	// inputBaseSize is the size of an input without its scriptSig: the
+	// outpoint and the sequence.
+	inputBaseSize = 32 + 4 + 4
+)
+
+// ErrNoSatisfaction is returned for scripts whose satisfaction is unknown,
+// like the ones of addr() and raw().
+var ErrNoSatisfaction = errors.New("script satisfaction is unknown")
+
+// Satisfaction is the maximum size of the scriptSig and witness spending a
+// script, assuming the largest signatures.
+type Satisfaction struct {
+	// ScriptSig is the size of the scriptSig, without its length prefix.
+	ScriptSig int
+	// Witness are the sizes of the witness items, without their length
+	// prefixes. It's nil for inputs without witness.
+	Witness []int
+}
+
+// WitnessSize returns the serialized size of the witness.
+func (s *Satisfaction) WitnessSize() int {
+	if s.Witness == nil {
+		return 0
+	}
+	return len(compactSize(len(s.Witness))) + witnessItemsSize(s.Witness)
+}
+
+// Weight returns the weight of the input, which is 4 times its size without
+// witness plus the witness size. The virtual size of a transaction is its
+// weight divided by 4. Inputs without witness spent along segwit ones take one
+// extra weight unit for their empty witness, which isn't included and must be
+// added by the caller.
+func (s *Satisfaction) Weight() int {
+	size := inputBaseSize + len(compactSize(s.ScriptSig)) + s.ScriptSig
+	return 4*size + s.WitnessSize()
+}
+
+// MaxSatisfaction returns the maximum size of the input spending the script.
+// Taproot outputs are assumed to be spent through the key path, see
+// Taproot.LeafSatisfaction for script paths. ErrNoSatisfaction is returned
+// for scripts whose satisfaction is unknown.
+func (s *Script) MaxSatisfaction() (*Satisfaction, error) {
+	switch {
+	case s.satisfaction != nil:
+		return &Satisfaction{
+			ScriptSig: s.satisfaction.ScriptSig,
+			Witness:   append([]int(nil), s.satisfaction.Witness...),
+		}, nil
+	case s.stack != nil:
+		return &Satisfaction{ScriptSig: pushesSize(s.stack)}, nil
+	}
+	return nil, ErrNoSatisfaction
+}
+
+// LeafSatisfaction returns the maximum size of the input spending the output
+// through the given leaf, including the leaf script and its control block.
+func (t *Taproot) LeafSatisfaction(leaf TapLeaf) (*Satisfaction, error) {
+	for _, l := range t.leaves {
+		if l.leaf.Version != leaf.Version || !bytes.Equal(l.leaf.Script, leaf.Script) {
+			continue
+		}
+		if l.stack == nil {
+			return nil, ErrNoSatisfaction
+		}
+
+		controlBlock := 1 + len(t.InternalKey) + 32*len(l.path)
+		witness := append(append([]int(nil), l.stack...), len(leaf.Script), controlBlock)
+		return &Satisfaction{Witness: witness}, nil
+	}
+
+	return nil, errors.New("leaf not found in script tree")
+}
+
+// pushSize returns the size of the smallest push of n bytes of data.
+func pushSize(n int) int {
+	return len(pushData(make([]byte, n)))
+}
+
+// pushesSize returns the size of a scriptSig pushing items of the given sizes.
+func pushesSize(items []int) int {
+	size := 0
+	for _, n := range items {
+		size += pushSize(n)
+	}
+	return size
+}
+
+// witnessItemsSize returns the size of witness items of the given sizes,
+// including their length prefixes.
+func witnessItemsSize(items []int) int {
+	size := 0
+	for _, n := range items {
+		size += len(compactSize(n)) + n
+	}
+	return size
+}
+
+// shSatisfaction returns the satisfaction of a sh() output given its
+// redeem script, which is the evaluated script it wraps.
+func shSatisfaction(redeem *Script) *Satisfaction {
+	redeemPush := pushSize(len(redeem.Bytes()))
+	if _, _, ok := witnessProgram(redeem.Bytes()); ok {
+		if redeem.satisfaction == nil {
+			return nil
+		}
+		return &Satisfaction{
+			ScriptSig: redeemPush,
+			Witness:   redeem.satisfaction.Witness,
+		}
+	}
+
+	if redeem.stack == nil {
+		return nil
+	}
+	return &Satisfaction{ScriptSig: pushesSize(redeem.stack) + redeemPush}
+}
+
+// msSat is a miniscript satisfaction or dissatisfaction, which might not be
+// possible.
+type msSat struct {
+	ok bool
+	// items are the sizes of the stack items in witness order, the last one
+	// being the top of the stack.
+	items []int
+}
+
+var msNoSat = msSat{}
+
+func msItems(items ...int) msSat {
+	return msSat{ok: true, items: items}
+}
+
+// and returns the satisfaction made of both s and o, with the items of o
+// pushed on top.
+func (s msSat) and(o msSat) msSat {
+	if !s.ok || !o.ok {
+		return msNoSat
+	}
+	return msItems(append(append([]int(nil), s.items...), o.items...)...)
+}
+
+// size returns the witness size of the satisfaction.
+func (s msSat) size() int {
+	return witnessItemsSize(s.items)
+}
+
+// msMaxSat returns the largest of the possible satisfactions.
+func msMaxSat(sats ...msSat) msSat {
+	max := msNoSat
+	for _, s := range sats {
+		if s.ok && (!max.ok || s.size() > max.size()) {
+			max = s
+		}
+	}
+	return max
+}
+
+// satisfaction returns the largest non malleable satisfaction and
+// dissatisfaction of the fragment in ctx, as per the BIP379 satisfaction
+// table.
+func (n *msNode) satisfaction(ctx msContext) (sat, dsat msSat) {
+	sigSize, keySize := maxECDSASigSize, 33
+	if ctx == msTapscript {
+		sigSize, keySize = maxSchnorrSigSize, 32
+	}
+
+	subs := make([][2]msSat, len(n.subs))
+	for i, sub := range n.subs {
+		subs[i][0], subs[i][1] = sub.satisfaction(ctx)
+	}
+
+	var x, y, z [2]msSat
+	switch len(subs) {
+	case 3:
+		z = subs[2]
+		fallthrough
+	case 2:
+		y = subs[1]
+		fallthrough
+	case 1:
+		x = subs[0]
+	}
+
+	switch n.frag {
+	case msJust0:
+		return msNoSat, msItems()
+	case msJust1:
+		return msItems(), msNoSat
+	case msPkK:
+		return msItems(sigSize), msItems(0)
+	case msPkH:
+		return msItems(sigSize, keySize), msItems(0, keySize)
+	case msOlder, msAfter:
+		return msItems(), msNoSat
+	case msSha256, msHash256, msRipemd160, msHash160:
+		return msItems(32), msItems(32)
+	case msWrapA, msWrapS, msWrapC, msWrapN:
+		return x[0], x[1]
+	case msWrapD:
+		return x[0].and(msItems(1)), msItems(0)
+	case msWrapV:
+		return x[0], msNoSat
+	case msWrapJ:
+		return x[0], msItems(0)
+	case msAndV:
+		return y[0].and(x[0]), msNoSat
+	case msAndB:
+		return y[0].and(x[0]), y[1].and(x[1])
+	case msOrB:
+		return msMaxSat(y[0].and(x[1]), y[1].and(x[0])), y[1].and(x[1])
+	case msOrC:
+		return msMaxSat(x[0], y[0].and(x[1])), msNoSat
+	case msOrD:
+		return msMaxSat(x[0], y[0].and(x[1])), y[1].and(x[1])
+	case msOrI:
+		return msMaxSat(x[0].and(msItems(1)), y[0].and(msItems(0))),
+			msMaxSat(x[1].and(msItems(1)), y[1].and(msItems(0)))
+	case msAndOr:
+		return msMaxSat(y[0].and(x[0]), z[0].and(x[1])), z[1].and(x[1])
+	case msThresh:
+		return threshSatisfaction(int(n.k), subs)
+	case msMulti:
+		sat := []int{0}
+		for i := 0; i < int(n.k); i++ {
+			sat = append(sat, sigSize)
+		}
+		return msItems(sat...), msItems(make([]int, n.k+1)...)
+	case msMultiA:
+		sat := make([]int, len(n.keys))
+		for i := 0; i < int(n.k); i++ {
+			sat[i] = sigSize
+		}
+		return msItems(sat...), msItems(make([]int, len(n.keys))...)
+	}
+
+	return msNoSat, msNoSat
+}
+
+// threshSatisfaction returns the largest satisfaction of thresh(), which
+// satisfies the k subexpressions growing the most when satisfied and
+// dissatisfies the rest.
+func threshSatisfaction(k int, subs [][2]msSat) (sat, dsat msSat) {
+	// The first subexpression takes the top of the stack.
+	dsat = msItems()
+	for i := len(subs) - 1; i >= 0; i-- {
+		dsat = dsat.and(subs[i][1])
+	}
+
+	growth := make([]int, 0, len(subs))
+	for i, sub := range subs {
+		if sub[0].ok && sub[1].ok {
+			growth = append(growth, i)
+		}
+	}
+	if len(growth) < k || !dsat.ok {
+		return msNoSat, dsat
+	}
+
+	delta := func(i int) int { return subs[i][0].size() - subs[i][1].size() }
+	sort.SliceStable(growth, func(i, j int) bool {
+		return delta(growth[i]) > delta(growth[j])
+	})
+
+	satisfied := make(map[int]bool, k)
+	for _, i := range growth[:k] {
+		satisfied[i] = true
+	}
+
+	sat = msItems()
+	for i := len(subs) - 1; i >= 0; i-- {
+		if satisfied[i] {
+			sat = sat.and(subs[i][0])
+		} else {
+			sat = sat.and(subs[i][1])
+		}
+	}
+	return sat, dsat
+}
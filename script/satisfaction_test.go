@@ -0,0 +1,139 @@
+package script_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/qustavo/go-wallet/script"
+)
+
+func TestMaxSatisfaction(t *testing.T) {
+	const keyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	multi := "multi(2," + compressedKey + "," + keyB + ")"
+
+	testCases := []struct {
+		name      string
+		desc      string
+		scriptSig int
+		witness   []int
+		weight    int
+	}{
+		{
+			name:      "pk",
+			desc:      "pk(" + compressedKey + ")",
+			scriptSig: 1 + 73,
+			weight:    4 * (40 + 1 + 74),
+		},
+		{
+			name:      "pkh",
+			desc:      "pkh(" + compressedKey + ")",
+			scriptSig: 1 + 73 + 1 + 33,
+			weight:    4 * (40 + 1 + 108),
+		},
+		{
+			name:      "pkh uncompressed",
+			desc:      "pkh(" + uncompressedKey + ")",
+			scriptSig: 1 + 73 + 1 + 65,
+			weight:    4 * (40 + 1 + 140),
+		},
+		{
+			name:    "wpkh",
+			desc:    "wpkh(" + compressedKey + ")",
+			witness: []int{73, 33},
+			weight:  4*(40+1) + 1 + 74 + 34,
+		},
+		{
+			name:      "sh(wpkh)",
+			desc:      "sh(wpkh(" + compressedKey + "))",
+			scriptSig: 1 + 22,
+			witness:   []int{73, 33},
+			weight:    4*(40+1+23) + 1 + 74 + 34,
+		},
+		{
+			// The dummy item, the signatures and the redeem script.
+			name:      "sh(multi)",
+			desc:      "sh(" + multi + ")",
+			scriptSig: 1 + 2*(1+73) + 1 + 71,
+			weight:    4 * (40 + 1 + 221),
+		},
+		{
+			name:    "wsh(multi)",
+			desc:    "wsh(" + multi + ")",
+			witness: []int{0, 73, 73, 71},
+			weight:  4*(40+1) + 1 + 1 + 2*74 + 72,
+		},
+		{
+			name:      "sh(wsh(multi))",
+			desc:      "sh(wsh(" + multi + "))",
+			scriptSig: 1 + 34,
+			witness:   []int{0, 73, 73, 71},
+			weight:    4*(40+1+35) + 1 + 1 + 2*74 + 72,
+		},
+		{
+			// The largest satisfaction dissatisfies pk(A) to spend through B.
+			name:    "wsh(miniscript)",
+			desc:    "wsh(or_d(pk(" + compressedKey + "),and_v(v:pk(" + keyB + "),older(144))))",
+			witness: []int{73, 0, 77},
+			weight:  4*(40+1) + 1 + 74 + 1 + 78,
+		},
+		{
+			name:    "tr key path",
+			desc:    "tr(" + xOnlyKey + ",pk(" + keyB + "))",
+			witness: []int{65},
+			weight:  4*(40+1) + 1 + 66,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := Parse(test.desc)
+			require.NoError(t, err)
+
+			sat, err := script.MaxSatisfaction()
+			require.NoError(t, err)
+			assert.Equal(t, test.scriptSig, sat.ScriptSig)
+			assert.Equal(t, test.witness, sat.Witness)
+			assert.Equal(t, test.weight, sat.Weight())
+		})
+	}
+}
+
+func TestMaxSatisfactionUnknown(t *testing.T) {
+	for _, desc := range []string{
+		"addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)",
+		"raw(6a00)",
+	} {
+		script, err := Parse(desc)
+		require.NoError(t, err, desc)
+
+		_, err = script.MaxSatisfaction()
+		assert.ErrorIs(t, err, ErrNoSatisfaction, desc)
+	}
+}
+
+func TestLeafSatisfaction(t *testing.T) {
+	const keyB = "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+
+	script, err := Parse("tr(" + xOnlyKey + ",{pk(" + keyB + "),multi_a(1," + xOnlyKey + "," + keyB + ")})")
+	require.NoError(t, err)
+
+	leaves := script.Taproot().Leaves()
+	require.Len(t, leaves, 2)
+
+	// The signature, the leaf script and a control block with one node.
+	sat, err := script.Taproot().LeafSatisfaction(leaves[0])
+	require.NoError(t, err)
+	assert.Equal(t, 0, sat.ScriptSig)
+	assert.Equal(t, []int{65, 34, 65}, sat.Witness)
+	assert.Equal(t, 4*(40+1)+1+66+35+66, sat.Weight())
+
+	// Keys without a signature take an empty item.
+	sat, err = script.Taproot().LeafSatisfaction(leaves[1])
+	require.NoError(t, err)
+	assert.Equal(t, []int{65, 0, 70, 65}, sat.Witness)
+
+	_, err = script.Taproot().LeafSatisfaction(TapLeaf{Version: TapLeafVersion, Script: []byte{OP_1}})
+	assert.Error(t, err)
+}
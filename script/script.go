@@ -1,65 +1,180 @@
 package script
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/btcsuite/btcutil/base58"
 )
 
-type Network int
+type Script struct {
+	bytes   []byte
+	addrFn  func(*NetParams) string
+	taproot *Taproot
+	keys    []DerivedKey
+
+	// inner is the script wrapped by sh() and wsh().
+	inner         *Script
+	redeemScript  []byte
+	witnessScript []byte
+
+	// stack are the sizes of the items satisfying the script when executed,
+	// nil if unknown, and satisfaction is the one of the output when it
+	// isn't made of these pushed items alone.
+	stack        []int
+	satisfaction *Satisfaction
+}
 
-const (
-	Mainnet Network = iota
-	Testnet
-	Regtest
-)
+// DerivedKey is a public key used by a script along with its origin, which is
+// nil when unknown.
+type DerivedKey struct {
+	PubKey []byte
+	Origin *KeyOrigin
+}
 
-var DefaultNetwork = Mainnet
+func (s *Script) Bytes() []byte {
+	return s.bytes
+}
 
-type netParams struct {
-	p2pkh  byte
-	p2sh   byte
-	bech32 string
+// Keys returns the public keys used by the script, including the ones of
+// nested scripts and Taproot leaves.
+func (s *Script) Keys() []DerivedKey {
+	return s.keys
 }
 
-var networks = map[Network]netParams{
-	Mainnet: {
-		p2pkh:  0x00,
-		p2sh:   0x05,
-		bech32: "bc",
-	},
-	Testnet: {
-		p2pkh:  0x6F,
-		p2sh:   0xC4,
-		bech32: "tb",
-	},
-	Regtest: {
-		p2pkh:  0x6F,
-		p2sh:   0xC4,
-		bech32: "bcrt",
-	},
+// Taproot returns the Taproot spending details of a tr() output, or nil for
+// any other kind of script.
+func (s *Script) Taproot() *Taproot {
+	return s.taproot
 }
 
-type Script struct {
-	bytes  []byte
-	addrFn func(Network) string
+// RedeemScript returns the redeem script of a sh() output, which is the
+// witness program for sh(wpkh()) and sh(wsh()), or nil for any other kind of
+// script.
+func (s *Script) RedeemScript() []byte {
+	return s.redeemScript
 }
 
-func (s *Script) Bytes() []byte {
-	return s.bytes
+// WitnessScript returns the witness script of a wsh() or sh(wsh()) output,
+// or nil for any other kind of script.
+func (s *Script) WitnessScript() []byte {
+	return s.witnessScript
+}
+
+// Inner returns the script wrapped by sh() or wsh(), or nil for any other
+// kind of script.
+func (s *Script) Inner() *Script {
+	return s.inner
 }
 
-func (s *Script) Address(net Network) string {
+// ErrNoAddress is returned for scripts that can't be encoded as an address,
+// like bare multi() or pk() outputs.
+var ErrNoAddress = errors.New("script has no address")
+
+// Address returns the address of the script on the given network, or an
+// empty string if it has none. See EncodeAddress.
+func (s *Script) Address(net *NetParams) string {
+	addr, _ := s.EncodeAddress(net)
+	return addr
+}
+
+// EncodeAddress returns the address of the script on the given network,
+// ErrNoAddress is returned if the script has no address.
+func (s *Script) EncodeAddress(net *NetParams) (string, error) {
+	if net == nil {
+		return "", ErrUnknownNetwork
+	}
 	if s.addrFn == nil {
-		return "<not implemented>"
+		return "", ErrNoAddress
 	}
-	return s.addrFn(net)
+
+	addr := s.addrFn(net)
+	if addr == "" {
+		return "", ErrNoAddress
+	}
+	return addr, nil
 }
 
 type ScriptExpr interface {
 	Eval() (*Script, error)
+	// String returns the descriptor of the expression, including its
+	// checksum.
+	String() string
+}
+
+// withChecksum appends the checksum to a descriptor.
+func withChecksum(desc string) string {
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		// Descriptors with invalid characters can't have a checksum.
+		return desc
+	}
+	return desc + "#" + checksum
+}
+
+// descriptor returns the descriptor of expr without its checksum, which is
+// needed when nesting expressions.
+func descriptor(expr ScriptExpr) string {
+	desc := expr.String()
+	if i := strings.LastIndexByte(desc, '#'); i >= 0 {
+		return desc[:i]
+	}
+	return desc
+}
+
+// MultiScriptExpr is implemented by expressions that evaluate to more than one
+// output script, such as combo().
+type MultiScriptExpr interface {
+	ScriptExpr
+	EvalAll() ([]*Script, error)
 }
 
+// EvalAll evaluates expr returning all of its output scripts.
+func EvalAll(expr ScriptExpr) ([]*Script, error) {
+	if multi, ok := expr.(MultiScriptExpr); ok {
+		return multi.EvalAll()
+	}
+
+	eval, err := expr.Eval()
+	if err != nil {
+		return nil, err
+	}
+	return []*Script{eval}, nil
+}
+
+// evalLegacyKey evaluates a key used outside Taproot, which can't be x-only.
+func evalLegacyKey(s string) (*PubKey, *KeyOrigin, error) {
+	key, origin, err := evalKey(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.IsXOnly() {
+		return nil, nil, fmt.Errorf("x-only key '%s' is only allowed in tr()", s)
+	}
+	return key, origin, nil
+}
+
+// checkSegWitKeys verifies that keys can be used in segwit v0 scripts, which
+// only allow compressed keys as per BIP143.
+func checkSegWitKeys(keys []DerivedKey) error {
+	for _, key := range keys {
+		if len(key.PubKey) != 33 {
+			return fmt.Errorf("uncompressed key '%x' is not allowed in segwit scripts", key.PubKey)
+		}
+	}
+	return nil
+}
+
+// maxRedeemScriptSize is the maximum size of a P2SH redeem script, which is
+// the maximum size of a stack element.
+const maxRedeemScriptSize = 520
+
 type p2Sh struct {
 	expr ScriptExpr
 }
@@ -70,12 +185,20 @@ func Sh(expr ScriptExpr) ScriptExpr {
 	}
 }
 
+func (s *p2Sh) String() string {
+	return withChecksum("sh(" + descriptor(s.expr) + ")")
+}
+
 func (s *p2Sh) Eval() (*Script, error) {
 	eval, err := s.expr.Eval()
 	if err != nil {
 		return nil, err
 	}
 
+	if len(eval.Bytes()) > maxRedeemScriptSize {
+		return nil, fmt.Errorf("sh() redeem script is %d bytes, larger than the %d bytes limit", len(eval.Bytes()), maxRedeemScriptSize)
+	}
+
 	hash160 := Hash160(eval.Bytes())
 	return &Script{
 		bytes: NewBytes(
@@ -83,9 +206,14 @@ func (s *p2Sh) Eval() (*Script, error) {
 			hash160,
 			[]byte{OP_EQUAL},
 		),
-		addrFn: func(net Network) string {
-			return base58.CheckEncode(hash160, networks[net].p2sh)
+		addrFn: func(net *NetParams) string {
+			return base58.CheckEncode(hash160, net.ScriptHashAddrID)
 		},
+		keys:          eval.keys,
+		inner:         eval,
+		redeemScript:  eval.Bytes(),
+		witnessScript: eval.witnessScript,
+		satisfaction:  shSatisfaction(eval),
 	}, nil
 }
 
@@ -99,26 +227,43 @@ func Wsh(expr ScriptExpr) ScriptExpr {
 	}
 }
 
+func (s *p2Wsh) String() string {
+	return withChecksum("wsh(" + descriptor(s.expr) + ")")
+}
+
 func (s *p2Wsh) Eval() (*Script, error) {
 	eval, err := s.expr.Eval()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkSegWitKeys(eval.keys); err != nil {
+		return nil, err
+	}
+
 	hash256 := Sha256(eval.Bytes())
+	addrFn, err := segWitAddrFn(0x00, hash256)
+	if err != nil {
+		return nil, err
+	}
+
+	var satisfaction *Satisfaction
+	if eval.stack != nil {
+		satisfaction = &Satisfaction{
+			Witness: append(append([]int(nil), eval.stack...), len(eval.Bytes())),
+		}
+	}
 
 	return &Script{
 		bytes: NewBytes(
 			[]byte{OP_0, OP_PUSH_BYTES(32)},
 			hash256,
 		),
-		addrFn: func(net Network) string {
-			addr, err := encodeSegWitAddress(networks[net].bech32, 0x00, hash256)
-			if err != nil {
-				panic(err)
-			}
-			return addr
-		},
+		addrFn:        addrFn,
+		keys:          eval.keys,
+		inner:         eval,
+		witnessScript: eval.Bytes(),
+		satisfaction:  satisfaction,
 	}, nil
 }
 
@@ -130,22 +275,54 @@ func Pkh(key string) ScriptExpr {
 	return &p2Pkh{key: key}
 }
 
+func (s *p2Pkh) String() string {
+	return withChecksum("pkh(" + s.key + ")")
+}
+
 func (s *p2Pkh) Eval() (*Script, error) {
-	key, err := NewPubKey(s.key)
+	return s.eval(false)
+}
+
+func (s *p2Pkh) evalTapscript() (*Script, error) {
+	return s.eval(true)
+}
+
+func (s *p2Pkh) eval(xonly bool) (*Script, error) {
+	eval := evalLegacyKey
+	if xonly {
+		eval = evalKey
+	}
+
+	key, origin, err := eval(s.key)
 	if err != nil {
 		return nil, err
 	}
 
-	hash160 := Hash160(key.Bytes())
+	keyBytes := key.Bytes()
+	if xonly {
+		keyBytes, err = xOnly(keyBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sigSize := maxECDSASigSize
+	if xonly {
+		sigSize = maxSchnorrSigSize
+	}
+
+	hash160 := Hash160(keyBytes)
 	script := &Script{
 		bytes: NewBytes(
 			[]byte{OP_DUP, OP_HASH160, OP_PUSH_BYTES(20)},
 			hash160,
 			[]byte{OP_EQUALVERIFY, OP_CHECKSIG},
 		),
-		addrFn: func(net Network) string {
-			return base58.CheckEncode(hash160, networks[net].p2pkh)
+		addrFn: func(net *NetParams) string {
+			return base58.CheckEncode(hash160, net.PubKeyHashAddrID)
 		},
+		keys:  []DerivedKey{{PubKey: keyBytes, Origin: origin}},
+		stack: []int{sigSize, len(keyBytes)},
 	}
 
 	return script, nil
@@ -159,82 +336,368 @@ func Wpkh(key string) ScriptExpr {
 	return &p2Wpkh{key: key}
 }
 
+func (s *p2Wpkh) String() string {
+	return withChecksum("wpkh(" + s.key + ")")
+}
+
 func (s *p2Wpkh) Eval() (*Script, error) {
-	key, err := NewPubKey(s.key)
+	key, origin, err := evalLegacyKey(s.key)
 	if err != nil {
 		return nil, err
 	}
 
+	keys := []DerivedKey{{PubKey: key.Bytes(), Origin: origin}}
+	if err := checkSegWitKeys(keys); err != nil {
+		return nil, err
+	}
+
 	hash160 := Hash160(key.Bytes())
+	addrFn, err := segWitAddrFn(0x00, hash160)
+	if err != nil {
+		return nil, err
+	}
+
 	script := &Script{
 		bytes: NewBytes(
 			[]byte{OP_0, OP_PUSH_BYTES(20)},
 			hash160,
 		),
-		addrFn: func(net Network) string {
-			// TODO: refactor encodeSegWitAddress so that we can compute the computed
-			// bits on Wpkh constructor and this method never returns error.
-			addr, err := encodeSegWitAddress(networks[net].bech32, 0x00, hash160)
-			if err != nil {
-				panic(err)
-			}
-			return addr
-		},
+		addrFn:       addrFn,
+		keys:         keys,
+		satisfaction: &Satisfaction{Witness: []int{maxECDSASigSize, len(keys[0].PubKey)}},
 	}
 
 	return script, nil
 }
 
 type multi struct {
-	m    int
-	keys []string
+	m      int
+	keys   []string
+	sorted bool
 }
 
 func Multi(m int, keys ...string) ScriptExpr {
-	return &multi{m, keys}
+	return &multi{m: m, keys: append([]string(nil), keys...)}
 }
 
+// Sortedmulti is like Multi but the derived keys are sorted by their bytes
+// (BIP67) when evaluated, so ranged keys might be ordered differently at each
+// index.
 func Sortedmulti(m int, keys ...string) ScriptExpr {
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
-	return &multi{m, keys}
+	return &multi{m: m, keys: append([]string(nil), keys...), sorted: true}
+}
+
+func (s *multi) String() string {
+	args := append([]string{strconv.Itoa(s.m)}, s.keys...)
+	return withChecksum(s.op() + "(" + strings.Join(args, ",") + ")")
+}
+
+// maxMultisigKeys is the maximum number of keys of OP_CHECKMULTISIG.
+const maxMultisigKeys = 20
+
+// checkThreshold verifies a k-of-n threshold, which must be 1 <= k <= n <= max.
+func checkThreshold(op string, k, n, max int) error {
+	if n > max {
+		return fmt.Errorf("%s() allows at most %d keys, got %d", op, max, n)
+	}
+	if k < 1 || k > n {
+		return fmt.Errorf("%s() threshold %d must be between 1 and the %d keys", op, k, n)
+	}
+	return nil
+}
+
+func (s *multi) op() string {
+	if s.sorted {
+		return "sortedmulti"
+	}
+	return "multi"
 }
 
 func (s *multi) Eval() (*Script, error) {
+	if err := checkThreshold(s.op(), s.m, len(s.keys), maxMultisigKeys); err != nil {
+		return nil, err
+	}
+
 	// Convert input keys from string into PubKey.
-	keys := make([]Key, len(s.keys))
+	keys := make([]DerivedKey, len(s.keys))
 	for i, str := range s.keys {
-		key, err := NewPubKey(str)
+		key, origin, err := evalLegacyKey(str)
 		if err != nil {
 			return nil, err
 		}
-		keys[i] = key
+		keys[i] = DerivedKey{PubKey: key.Bytes(), Origin: origin}
+	}
+
+	if s.sorted {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i].PubKey, keys[j].PubKey) < 0
+		})
 	}
 
 	pushedKeys := []byte{}
 	for _, key := range keys {
 		pushedKey := NewBytes(
-			[]byte{OP_PUSH_BYTES(33)},
-			key.Bytes(),
+			[]byte{OP_PUSH_BYTES(len(key.PubKey))},
+			key.PubKey,
 		)
 		pushedKeys = append(pushedKeys, pushedKey...)
 	}
 
+	// OP_CHECKMULTISIG pops an extra dummy item.
+	stack := []int{0}
+	for i := 0; i < s.m; i++ {
+		stack = append(stack, maxECDSASigSize)
+	}
+
+	return &Script{
+		bytes: NewBytes(
+			pushNumber(int64(s.m)),         // required keys
+			pushedKeys,                     // [ len <key_1> ... len <key_N> ]
+			pushNumber(int64(len(s.keys))), // total keys
+			[]byte{OP_CHECKMULTISIG},
+		),
+		keys:  keys,
+		stack: stack,
+	}, nil
+}
+
+// maxMultiAKeys is the maximum number of keys of multi_a(), as per BIP387.
+const maxMultiAKeys = 999
+
+type multiA struct {
+	m      int
+	keys   []string
+	sorted bool
+}
+
+// MultiA returns a k-of-n tapscript multisig, built out of OP_CHECKSIG and
+// OP_CHECKSIGADD as OP_CHECKMULTISIG is disabled in tapscript. It can only be
+// used in Taproot leaves.
+func MultiA(m int, keys ...string) ScriptExpr {
+	return &multiA{m: m, keys: append([]string(nil), keys...)}
+}
+
+// SortedmultiA is like MultiA but the x-only keys are sorted by their bytes
+// when evaluated.
+func SortedmultiA(m int, keys ...string) ScriptExpr {
+	return &multiA{m: m, keys: append([]string(nil), keys...), sorted: true}
+}
+
+func (s *multiA) op() string {
+	if s.sorted {
+		return "sortedmulti_a"
+	}
+	return "multi_a"
+}
+
+func (s *multiA) String() string {
+	args := append([]string{strconv.Itoa(s.m)}, s.keys...)
+	return withChecksum(s.op() + "(" + strings.Join(args, ",") + ")")
+}
+
+func (s *multiA) Eval() (*Script, error) {
+	return nil, fmt.Errorf("%s() is only allowed in tr() leaves", s.op())
+}
+
+func (s *multiA) evalTapscript() (*Script, error) {
+	if err := checkThreshold(s.op(), s.m, len(s.keys), maxMultiAKeys); err != nil {
+		return nil, err
+	}
+
+	keys := make([]DerivedKey, len(s.keys))
+	for i, str := range s.keys {
+		key, origin, err := evalKey(str)
+		if err != nil {
+			return nil, err
+		}
+
+		xonly, err := xOnly(key.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = DerivedKey{PubKey: xonly, Origin: origin}
+	}
+
+	if s.sorted {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i].PubKey, keys[j].PubKey) < 0
+		})
+	}
+
+	// <key_1> OP_CHECKSIG <key_2> OP_CHECKSIGADD ... <key_N> OP_CHECKSIGADD <m> OP_NUMEQUAL
+	var script []byte
+	for i, key := range keys {
+		op := byte(OP_CHECKSIGADD)
+		if i == 0 {
+			op = OP_CHECKSIG
+		}
+		script = NewBytes(script, []byte{OP_PUSH_BYTES(32)}, key.PubKey, []byte{op})
+	}
+
+	// Keys without a signature take an empty item.
+	stack := make([]int, len(keys))
+	for i := 0; i < s.m; i++ {
+		stack[i] = maxSchnorrSigSize
+	}
+
+	return &Script{
+		bytes: NewBytes(script, pushNumber(int64(s.m)), []byte{OP_NUMEQUAL}),
+		keys:  keys,
+		stack: stack,
+	}, nil
+}
+
+type pk struct {
+	key string
+}
+
+// Pk returns a bare P2PK `<key> OP_CHECKSIG` script, which has no address.
+// Inside Taproot leaves the key is serialized as x-only.
+func Pk(key string) ScriptExpr {
+	return &pk{key: key}
+}
+
+func (s *pk) String() string {
+	return withChecksum("pk(" + s.key + ")")
+}
+
+func (s *pk) Eval() (*Script, error) {
+	return s.eval(false)
+}
+
+func (s *pk) evalTapscript() (*Script, error) {
+	return s.eval(true)
+}
+
+func (s *pk) eval(xonly bool) (*Script, error) {
+	eval := evalLegacyKey
+	if xonly {
+		eval = evalKey
+	}
+
+	key, origin, err := eval(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := key.Bytes()
+	sigSize := maxECDSASigSize
+	if xonly {
+		keyBytes, err = xOnly(keyBytes)
+		if err != nil {
+			return nil, err
+		}
+		sigSize = maxSchnorrSigSize
+	}
+
 	return &Script{
 		bytes: NewBytes(
-			[]byte{OP_N(s.m)}, // required keys
-			pushedKeys,        // [ 33 <key_1> ... 33 <key_N> ]
-			[]byte{
-				OP_N(len(s.keys)), // total keys
-				OP_CHECKMULTISIG,
-			},
+			[]byte{OP_PUSH_BYTES(len(keyBytes))},
+			keyBytes,
+			[]byte{OP_CHECKSIG},
 		),
-		addrFn: func(net Network) string { return "" },
+		keys:  []DerivedKey{{PubKey: keyBytes, Origin: origin}},
+		stack: []int{sigSize},
 	}, nil
 }
 
-type Tree interface {
+type combo struct {
+	key string
+}
+
+// Combo returns an expression evaluating to the P2PK, P2PKH, P2WPKH and
+// P2SH-P2WPKH outputs of key. Segwit outputs are omitted for uncompressed keys.
+func Combo(key string) ScriptExpr {
+	return &combo{key: key}
+}
+
+// Eval returns the first output of the combo, use EvalAll to get all of them.
+func (s *combo) Eval() (*Script, error) {
+	scripts, err := s.EvalAll()
+	if err != nil {
+		return nil, err
+	}
+	return scripts[0], nil
+}
+
+func (s *combo) String() string {
+	return withChecksum("combo(" + s.key + ")")
+}
+
+func (s *combo) EvalAll() ([]*Script, error) {
+	key, _, err := evalKey(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := []ScriptExpr{Pk(s.key), Pkh(s.key)}
+	if key.IsCompressed() {
+		exprs = append(exprs, Wpkh(s.key), Sh(Wpkh(s.key)))
+	}
+
+	scripts := make([]*Script, len(exprs))
+	for i, expr := range exprs {
+		scripts[i], err = expr.Eval()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return scripts, nil
+}
+
+type addr struct {
+	addr string
+}
+
+// Addr returns the output script of an address.
+func Addr(address string) ScriptExpr {
+	return &addr{addr: address}
+}
+
+func (s *addr) String() string {
+	return withChecksum("addr(" + s.addr + ")")
+}
+
+func (s *addr) Eval() (*Script, error) {
+	for _, net := range Networks() {
+		bytes, err := DecodeAddress(s.addr, net)
+		if err != nil {
+			continue
+		}
+
+		return &Script{
+			bytes:  bytes,
+			addrFn: scriptAddrFn(bytes),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid address '%s'", s.addr)
+}
+
+type raw struct {
+	hex string
+}
+
+// Raw returns an output script given its hex encoding.
+func Raw(hexScript string) ScriptExpr {
+	return &raw{hex: hexScript}
+}
+
+func (s *raw) String() string {
+	return withChecksum("raw(" + s.hex + ")")
+}
+
+func (s *raw) Eval() (*Script, error) {
+	bytes, err := hex.DecodeString(s.hex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw script: %w", err)
+	}
+
+	return &Script{
+		bytes:  bytes,
+		addrFn: scriptAddrFn(bytes),
+	}, nil
 }
 
 type tr struct {
@@ -242,6 +705,8 @@ type tr struct {
 	tree Tree
 }
 
+// Tr returns a Taproot output with key as internal key and an optional script
+// tree, which can be nil.
 func Tr(key string, tree Tree) ScriptExpr {
 	return &tr{
 		key:  key,
@@ -249,6 +714,62 @@ func Tr(key string, tree Tree) ScriptExpr {
 	}
 }
 
+func (s *tr) String() string {
+	if s.tree == nil {
+		return withChecksum("tr(" + s.key + ")")
+	}
+	return withChecksum("tr(" + s.key + "," + s.tree.String() + ")")
+}
+
 func (s *tr) Eval() (*Script, error) {
-	return &Script{}, nil
+	key, origin, err := evalKey(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	internalKey, err := xOnly(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var node *tapNode
+	if s.tree != nil {
+		node, err = s.tree.evalTree(0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		node = &tapNode{}
+	}
+
+	outputKey, parity, err := taprootTweak(internalKey, node.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	addrFn, err := segWitAddrFn(0x01, outputKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Script{
+		bytes: NewBytes(
+			[]byte{OP_1, OP_PUSH_BYTES(32)},
+			outputKey,
+		),
+		addrFn: addrFn,
+		taproot: &Taproot{
+			InternalKey:     internalKey,
+			OutputKey:       outputKey,
+			OutputKeyParity: parity,
+			MerkleRoot:      node.hash,
+			leaves:          node.leaves,
+		},
+		// Key path spends only take a signature.
+		satisfaction: &Satisfaction{Witness: []int{maxSchnorrSigSize}},
+		keys: append(
+			[]DerivedKey{{PubKey: internalKey, Origin: origin}},
+			node.keys...,
+		),
+	}, nil
 }
@@ -1,15 +1,273 @@
 package script_test
 
 import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	. "github.com/qustavo/go-wallet/script"
 )
 
+const (
+	// Public keys of the private key 1.
+	compressedKey   = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	uncompressedKey = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	xOnlyKey        = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+)
+
 func TestInvalidKeys(t *testing.T) {
 	expr := Sh(Pkh("the_key"))
 	_, err := expr.Eval()
 	require.Error(t, err)
+
+	for _, key := range []string{
+		"",
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817",
+		"0579be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"04" + strings.Repeat("00", 64),
+		"0679be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8",
+		xOnlyKey,
+	} {
+		_, err := NewPubKey(key)
+		assert.Error(t, err, key)
+	}
+
+	for _, key := range []string{"", compressedKey, strings.Repeat("ff", 32)} {
+		_, err := NewXOnlyPubKey(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestUncompressedKeys(t *testing.T) {
+	for _, key := range []string{compressedKey, uncompressedKey} {
+		pub, err := NewPubKey(key)
+		require.NoError(t, err)
+		assert.Equal(t, key, pub.String())
+	}
+
+	script, err := Parse("pkh(" + uncompressedKey + ")")
+	require.NoError(t, err)
+	assert.Equal(t, "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm", script.Address(Mainnet))
+
+	script, err = Parse("sh(multi(2," + uncompressedKey + "," + compressedKey + "))")
+	require.NoError(t, err)
+	multi, err := Multi(2, uncompressedKey, compressedKey).Eval()
+	require.NoError(t, err)
+	assert.Equal(t, "5241"+uncompressedKey+"21"+compressedKey+"52ae", hex.EncodeToString(multi.Bytes()))
+	assert.Len(t, script.Keys(), 2)
+
+	for _, desc := range []string{
+		"wpkh(" + uncompressedKey + ")",
+		"sh(wpkh(" + uncompressedKey + "))",
+		"wsh(pk(" + uncompressedKey + "))",
+		"wsh(multi(2," + uncompressedKey + "," + compressedKey + "))",
+		"sh(wsh(sortedmulti(2," + compressedKey + "," + uncompressedKey + ")))",
+		"tr(" + uncompressedKey + ")",
+		"tr(" + xOnlyKey + ",pk(" + uncompressedKey + "))",
+	} {
+		_, err := Parse(desc)
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestXOnlyKeys(t *testing.T) {
+	_, err := Parse("tr(" + xOnlyKey + ",pk(" + xOnlyKey + "))")
+	require.NoError(t, err)
+
+	for _, desc := range []string{
+		"pk(" + xOnlyKey + ")",
+		"pkh(" + xOnlyKey + ")",
+		"wpkh(" + xOnlyKey + ")",
+		"sh(multi(2," + xOnlyKey + "," + compressedKey + "))",
+		"combo(" + xOnlyKey + ")",
+	} {
+		_, err := Parse(desc)
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestSortedmulti(t *testing.T) {
+	t.Run("BIP67", func(t *testing.T) {
+		keys := []string{
+			"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+			"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+		}
+		eval, err := Sortedmulti(2, keys...).Eval()
+		require.NoError(t, err)
+		assert.Equal(t, "522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae", hex.EncodeToString(eval.Bytes()))
+
+		// The keys given by the caller must be left untouched.
+		assert.Equal(t, "02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8", keys[0])
+	})
+
+	t.Run("ranged keys", func(t *testing.T) {
+		// BIP32 test vector 1, m and m/0'.
+		const (
+			xpub1 = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+			xpub2 = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+		)
+
+		// Keep track of the position of xpub1 in the sorted keys, which
+		// changes depending on the derivation index.
+		positions := make(map[int]bool)
+		for i := 0; i < 8; i++ {
+			path := "m/" + strconv.Itoa(i)
+			sorted, err := ParseWithPath("wsh(sortedmulti(2,"+xpub1+"/0/*,"+xpub2+"/0/*))", path)
+			require.NoError(t, err)
+			unsorted, err := ParseWithPath("wsh(multi(2,"+xpub1+"/0/*,"+xpub2+"/0/*))", path)
+			require.NoError(t, err)
+
+			keys := sorted.Keys()
+			require.Len(t, keys, 2)
+			assert.Negative(t, bytes.Compare(keys[0].PubKey, keys[1].PubKey), path)
+
+			// Origins are sorted along with their keys.
+			first := unsorted.Keys()[0]
+			if bytes.Equal(keys[0].PubKey, first.PubKey) {
+				assert.Equal(t, first.Origin, keys[0].Origin)
+				positions[0] = true
+			} else {
+				assert.Equal(t, first.Origin, keys[1].Origin)
+				positions[1] = true
+			}
+
+			expected, err := Wsh(Multi(2, hex.EncodeToString(keys[0].PubKey), hex.EncodeToString(keys[1].PubKey))).Eval()
+			require.NoError(t, err)
+			assert.Equal(t, expected.Bytes(), sorted.Bytes(), path)
+		}
+		assert.Len(t, positions, 2, "the order of the keys must change across indexes")
+	})
+}
+
+func TestMultiThresholds(t *testing.T) {
+	keys := func(n int) []string {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = compressedKey
+		}
+		return keys
+	}
+	pushed := strings.Repeat("21"+compressedKey, 17)
+
+	testCases := []struct {
+		m, n     int
+		expected string
+	}{
+		{1, 1, "5121" + compressedKey + "51ae"},
+		{16, 17, "60" + pushed + "0111ae"},
+		{17, 17, "0111" + pushed + "0111ae"},
+	}
+
+	for _, test := range testCases {
+		eval, err := Multi(test.m, keys(test.n)...).Eval()
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, hex.EncodeToString(eval.Bytes()))
+
+		_, err = eval.EncodeAddress(Mainnet)
+		assert.ErrorIs(t, err, ErrNoAddress)
+		assert.Equal(t, "", eval.Address(Mainnet))
+	}
+
+	eval, err := Multi(20, keys(20)...).Eval()
+	require.NoError(t, err)
+	assert.Equal(t, "0114", hex.EncodeToString(eval.Bytes()[:2]))
+
+	for _, test := range []struct{ m, n int }{{0, 1}, {-1, 2}, {3, 2}, {1, 21}} {
+		_, err := Multi(test.m, keys(test.n)...).Eval()
+		assert.Error(t, err)
+
+		desc := "multi(" + strconv.Itoa(test.m) + "," + strings.Join(keys(test.n), ",") + ")"
+		_, err = Parse(desc)
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr, desc)
+	}
+
+	// P2SH redeem scripts are limited to 520 bytes, which fit up to 15
+	// compressed keys.
+	_, err = Sh(Multi(1, keys(15)...)).Eval()
+	assert.NoError(t, err)
+	_, err = Sh(Multi(1, keys(16)...)).Eval()
+	assert.Error(t, err)
+	_, err = Wsh(Multi(1, keys(20)...)).Eval()
+	assert.NoError(t, err)
+}
+
+func TestNoAddress(t *testing.T) {
+	for _, desc := range []string{
+		"pk(" + compressedKey + ")",
+		"multi(1," + compressedKey + ")",
+		"raw(6a)",
+	} {
+		script, err := Parse(desc)
+		require.NoError(t, err)
+		_, err = script.EncodeAddress(Mainnet)
+		assert.ErrorIs(t, err, ErrNoAddress, desc)
+	}
+
+	script, err := Parse("pkh(" + compressedKey + ")")
+	require.NoError(t, err)
+	addr, err := script.EncodeAddress(Mainnet)
+	require.NoError(t, err)
+	assert.Equal(t, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", addr)
+}
+
+func TestNestedScripts(t *testing.T) {
+	const keyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+	multiDesc := "multi(2," + compressedKey + "," + keyB + ")"
+
+	multi, err := Parse(multiDesc)
+	require.NoError(t, err)
+	wsh, err := Parse("wsh(" + multiDesc + ")")
+	require.NoError(t, err)
+
+	t.Run("sh(wsh(multi))", func(t *testing.T) {
+		script, err := Parse("sh(wsh(" + multiDesc + "))")
+		require.NoError(t, err)
+
+		assert.Equal(t, wsh.Bytes(), script.RedeemScript())
+		assert.Equal(t, multi.Bytes(), script.WitnessScript())
+		assert.Equal(t, Hash160(script.RedeemScript()), script.Bytes()[2:22])
+		assert.Equal(t, Sha256(script.WitnessScript()), script.RedeemScript()[2:])
+
+		require.NotNil(t, script.Inner())
+		assert.Equal(t, wsh.Bytes(), script.Inner().Bytes())
+		assert.Nil(t, script.Inner().RedeemScript())
+		assert.Equal(t, multi.Bytes(), script.Inner().WitnessScript())
+
+		require.NotNil(t, script.Inner().Inner())
+		assert.Equal(t, multi.Bytes(), script.Inner().Inner().Bytes())
+		assert.Nil(t, script.Inner().Inner().Inner())
+	})
+
+	t.Run("sh(multi)", func(t *testing.T) {
+		script, err := Parse("sh(" + multiDesc + ")")
+		require.NoError(t, err)
+		assert.Equal(t, multi.Bytes(), script.RedeemScript())
+		assert.Nil(t, script.WitnessScript())
+		assert.Equal(t, multi.Bytes(), script.Inner().Bytes())
+	})
+
+	t.Run("sh(wpkh)", func(t *testing.T) {
+		wpkh, err := Parse("wpkh(" + compressedKey + ")")
+		require.NoError(t, err)
+		script, err := Parse("sh(wpkh(" + compressedKey + "))")
+		require.NoError(t, err)
+		assert.Equal(t, wpkh.Bytes(), script.RedeemScript())
+		assert.Nil(t, script.WitnessScript())
+	})
+
+	t.Run("not nested", func(t *testing.T) {
+		for _, desc := range []string{"pkh(" + compressedKey + ")", multiDesc, "tr(" + xOnlyKey + ")"} {
+			script, err := Parse(desc)
+			require.NoError(t, err)
+			assert.Nil(t, script.Inner(), desc)
+			assert.Nil(t, script.RedeemScript(), desc)
+			assert.Nil(t, script.WitnessScript(), desc)
+		}
+	})
 }
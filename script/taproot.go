@@ -0,0 +1,206 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// TapLeafVersion is the leaf version used by tapscript (BIP342).
+const TapLeafVersion byte = 0xc0
+
+// maxTapTreeDepth is the maximum depth of a Taproot script tree as defined by
+// BIP341.
+const maxTapTreeDepth = 128
+
+// TapLeaf is an evaluated leaf of a Taproot script tree.
+type TapLeaf struct {
+	Version byte
+	Script  []byte
+}
+
+// Hash returns the TapLeaf tagged hash of the leaf.
+func (l TapLeaf) Hash() []byte {
+	return TaggedHash("TapLeaf",
+		[]byte{l.Version},
+		compactSize(len(l.Script)),
+		l.Script,
+	)
+}
+
+func tapBranchHash(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return TaggedHash("TapBranch", a, b)
+}
+
+// tapLeafPath is a leaf along with the merkle path (from the leaf to the root)
+// needed to prove its inclusion in the tree.
+type tapLeafPath struct {
+	leaf TapLeaf
+	path [][]byte
+	// stack are the sizes of the items satisfying the leaf script.
+	stack []int
+}
+
+// tapNode is an evaluated Tree.
+type tapNode struct {
+	hash   []byte
+	leaves []tapLeafPath
+	keys   []DerivedKey
+}
+
+// Tree is a Taproot script tree as used by tr(KEY, TREE). Trees are built
+// out of leaves (Leaf) and branches (Branch).
+type Tree interface {
+	evalTree(depth int) (*tapNode, error)
+	// String returns the descriptor representation of the tree.
+	String() string
+}
+
+// tapscriptExpr is implemented by expressions which evaluate differently
+// inside tapscript leaves, like the ones using x-only keys.
+type tapscriptExpr interface {
+	evalTapscript() (*Script, error)
+}
+
+type tapLeaf struct {
+	version byte
+	expr    ScriptExpr
+}
+
+// Leaf returns a tapscript leaf holding expr.
+func Leaf(expr ScriptExpr) Tree {
+	return LeafWithVersion(TapLeafVersion, expr)
+}
+
+// LeafWithVersion returns a leaf holding expr with a custom leaf version.
+func LeafWithVersion(version byte, expr ScriptExpr) Tree {
+	return &tapLeaf{
+		version: version,
+		expr:    expr,
+	}
+}
+
+func (l *tapLeaf) String() string {
+	return descriptor(l.expr)
+}
+
+func (l *tapLeaf) evalTree(depth int) (*tapNode, error) {
+	// Leaf versions must be even and can't collide with the annex tag.
+	if l.version&0x01 != 0 || l.version == 0x50 {
+		return nil, fmt.Errorf("invalid leaf version 0x%02x", l.version)
+	}
+
+	var (
+		eval *Script
+		err  error
+	)
+	if tap, ok := l.expr.(tapscriptExpr); ok {
+		eval, err = tap.evalTapscript()
+	} else {
+		eval, err = l.expr.Eval()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := TapLeaf{Version: l.version, Script: eval.Bytes()}
+	return &tapNode{
+		hash:   leaf.Hash(),
+		leaves: []tapLeafPath{{leaf: leaf, stack: eval.stack}},
+		keys:   eval.keys,
+	}, nil
+}
+
+type tapBranch struct {
+	left, right Tree
+}
+
+// Branch returns a tree node with left and right as children.
+func Branch(left, right Tree) Tree {
+	return &tapBranch{
+		left:  left,
+		right: right,
+	}
+}
+
+func (b *tapBranch) String() string {
+	return "{" + b.left.String() + "," + b.right.String() + "}"
+}
+
+func (b *tapBranch) evalTree(depth int) (*tapNode, error) {
+	if depth >= maxTapTreeDepth {
+		return nil, errors.New("taproot tree exceeds maximum depth")
+	}
+
+	left, err := b.left.evalTree(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := b.right.evalTree(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every leaf on one side needs the sibling's hash to reach the root.
+	var leaves []tapLeafPath
+	extend := func(l tapLeafPath, sibling []byte) tapLeafPath {
+		path := make([][]byte, len(l.path), len(l.path)+1)
+		copy(path, l.path)
+		return tapLeafPath{leaf: l.leaf, path: append(path, sibling), stack: l.stack}
+	}
+	for _, l := range left.leaves {
+		leaves = append(leaves, extend(l, right.hash))
+	}
+	for _, l := range right.leaves {
+		leaves = append(leaves, extend(l, left.hash))
+	}
+
+	return &tapNode{
+		hash:   tapBranchHash(left.hash, right.hash),
+		leaves: leaves,
+		keys:   append(append([]DerivedKey(nil), left.keys...), right.keys...),
+	}, nil
+}
+
+// Taproot holds the details of an evaluated tr() output needed to spend it.
+type Taproot struct {
+	InternalKey []byte
+	OutputKey   []byte
+	// OutputKeyParity is the parity of the output key's y coordinate.
+	OutputKeyParity byte
+	// MerkleRoot is nil for outputs without a script tree.
+	MerkleRoot []byte
+
+	leaves []tapLeafPath
+}
+
+// Leaves returns the leaves of the script tree in depth-first order.
+func (t *Taproot) Leaves() []TapLeaf {
+	leaves := make([]TapLeaf, len(t.leaves))
+	for i, l := range t.leaves {
+		leaves[i] = l.leaf
+	}
+	return leaves
+}
+
+// ControlBlock returns the control block required to spend the output
+// through the given leaf.
+func (t *Taproot) ControlBlock(leaf TapLeaf) ([]byte, error) {
+	for _, l := range t.leaves {
+		if l.leaf.Version != leaf.Version || !bytes.Equal(l.leaf.Script, leaf.Script) {
+			continue
+		}
+
+		cb := NewBytes([]byte{leaf.Version | t.OutputKeyParity}, t.InternalKey)
+		for _, node := range l.path {
+			cb = append(cb, node...)
+		}
+		return cb, nil
+	}
+
+	return nil, errors.New("leaf not found in script tree")
+}
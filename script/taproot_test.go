@@ -0,0 +1,189 @@
+package script_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/qustavo/go-wallet/script"
+)
+
+func TestTaprootScriptTree(t *testing.T) {
+	// Test vectors from BIP341 wallet test vectors (scriptPubKey section).
+	testCases := []struct {
+		name                 string
+		script               string
+		expectedScript       string
+		expectedAddr         string
+		expectedControlBlock string
+	}{
+		{
+			name:                 "single leaf",
+			script:               "tr(187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27,pk(d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8))",
+			expectedScript:       "5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			expectedAddr:         "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586",
+			expectedControlBlock: "c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+		},
+		{
+			name:                 "single leaf with even output key",
+			script:               "tr(93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820,pk(b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007))",
+			expectedScript:       "5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+			expectedAddr:         "bc1punvppl2stp38f7kwv2u2spltjuvuaayuqsthe34hd2dyy5w4g58qqfuag5",
+			expectedControlBlock: "c093478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := Parse(test.script)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedScript, hex.EncodeToString(script.Bytes()))
+			assert.Equal(t, test.expectedAddr, script.Address(Mainnet))
+
+			leaves := script.Taproot().Leaves()
+			require.Len(t, leaves, 1)
+
+			cb, err := script.Taproot().ControlBlock(leaves[0])
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedControlBlock, hex.EncodeToString(cb))
+		})
+	}
+}
+
+func TestTaprootControlBlocks(t *testing.T) {
+	script, err := Parse(`tr(93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820,{
+		pk(b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007),
+		{
+			pk(d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8),
+			pkh(02d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8)
+		}
+	})`)
+	require.NoError(t, err)
+
+	taproot := script.Taproot()
+	leaves := taproot.Leaves()
+	require.Len(t, leaves, 3)
+
+	for _, leaf := range leaves {
+		cb, err := taproot.ControlBlock(leaf)
+		require.NoError(t, err)
+		require.Equal(t, 0, (len(cb)-33)%32)
+
+		// Recompute the merkle root out of the control block path.
+		hash := leaf.Hash()
+		for i := 33; i < len(cb); i += 32 {
+			node := cb[i : i+32]
+			if bytes.Compare(hash, node) > 0 {
+				hash, node = node, hash
+			}
+			hash = TaggedHash("TapBranch", hash, node)
+		}
+		assert.Equal(t, taproot.MerkleRoot, hash)
+		assert.Equal(t, taproot.InternalKey, cb[1:33])
+		assert.Equal(t, TapLeafVersion|taproot.OutputKeyParity, cb[0])
+	}
+
+	// The fluent API must produce the same output.
+	expr := Tr("93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820", Branch(
+		Leaf(Pk("b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007")),
+		Branch(
+			Leaf(Pk("d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8")),
+			Leaf(Pkh("d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8")),
+		),
+	))
+	eval, err := expr.Eval()
+	require.NoError(t, err)
+	assert.Equal(t, script.Bytes(), eval.Bytes())
+
+	_, err = taproot.ControlBlock(TapLeaf{Version: TapLeafVersion, Script: []byte{OP_1}})
+	assert.Error(t, err)
+}
+
+func TestTaprootInvalidTrees(t *testing.T) {
+	const key = "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"
+
+	for _, desc := range []string{
+		"tr(" + key + ",{pk(" + key + ")})",
+		"tr(" + key + ",{pk(" + key + "),pk(" + key + "),pk(" + key + ")})",
+		"tr(" + key + ",{pk(" + key + "),pk(" + key + ")}",
+		"tr(" + key + ",wpkh(02" + key + "))",
+		"tr(" + key + ",multi(1," + key + "))",
+		"tr(" + key + ",tr(" + key + "))",
+	} {
+		_, err := Parse(desc)
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestMultiA(t *testing.T) {
+	const (
+		internal = "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"
+		key1     = "d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8"
+		key2     = "b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007"
+		key3     = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+		xonly3   = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	)
+
+	testCases := []struct {
+		leaf       string
+		expr       ScriptExpr
+		leafScript string
+	}{
+		{
+			leaf:       "multi_a(2," + key1 + "," + key2 + "," + key3 + ")",
+			expr:       MultiA(2, key1, key2, key3),
+			leafScript: "20" + key1 + "ac20" + key2 + "ba20" + xonly3 + "ba529c",
+		},
+		{
+			leaf:       "sortedmulti_a(1," + key1 + "," + key2 + "," + key3 + ")",
+			expr:       SortedmultiA(1, key1, key2, key3),
+			leafScript: "20" + xonly3 + "ac20" + key2 + "ba20" + key1 + "ba519c",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.leaf, func(t *testing.T) {
+			script, err := Parse("tr(" + internal + "," + test.leaf + ")")
+			require.NoError(t, err)
+
+			leaves := script.Taproot().Leaves()
+			require.Len(t, leaves, 1)
+			assert.Equal(t, test.leafScript, hex.EncodeToString(leaves[0].Script))
+			assert.Len(t, script.Keys(), 4)
+
+			eval, err := Tr(internal, Leaf(test.expr)).Eval()
+			require.NoError(t, err)
+			assert.Equal(t, script.Bytes(), eval.Bytes())
+			assert.Equal(t, test.leaf, trimChecksum(test.expr.String()))
+		})
+	}
+
+	_, err := MultiA(1, key1).Eval()
+	assert.Error(t, err)
+
+	for _, desc := range []string{
+		"multi_a(1," + key1 + ")",
+		"sh(multi_a(1," + key1 + "))",
+		"wsh(multi_a(1," + key1 + "))",
+		"wsh(sortedmulti_a(1," + key3 + "))",
+		"tr(" + internal + ",multi_a(0," + key1 + "))",
+		"tr(" + internal + ",multi_a(2," + key1 + "))",
+		"tr(" + internal + ",multi_a(1))",
+		"tr(" + internal + ",multi_a(1,0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8))",
+	} {
+		_, err := Parse(desc)
+		assert.Error(t, err, desc)
+	}
+}
+
+func trimChecksum(desc string) string {
+	if i := strings.LastIndexByte(desc, '#'); i >= 0 {
+		return desc[:i]
+	}
+	return desc
+}
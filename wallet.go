@@ -6,32 +6,76 @@ import (
 
 type Wallet struct {
 	desc    string
-	script  *script.Script
-	network script.Network
+	path    string
+	scripts []*script.Script
+	network *script.NetParams
+	// chains holds the descriptors of each chain of a multipath descriptor.
+	chains []string
 }
 
-func NewWallet(desc string, net script.Network) (*Wallet, error) {
+func NewWallet(desc string, net *script.NetParams) (*Wallet, error) {
 	return newWallet(desc, net, "")
 }
 
-func newWallet(desc string, net script.Network, path string) (*Wallet, error) {
-	script, err := script.ParseWithPath(desc, path)
+func newWallet(desc string, net *script.NetParams, path string) (*Wallet, error) {
+	chains, err := script.ExpandMultipath(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multipath wallets use their first (receive) chain.
+	scripts, err := script.ParseAllWithPath(chains[0], path)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Wallet{
 		desc:    desc,
-		script:  script,
+		path:    path,
+		scripts: scripts,
 		network: net,
+		chains:  chains,
 	}, nil
 
 }
 
+// Address returns the first address of the wallet.
 func (w *Wallet) Address() string {
-	return w.script.Address(w.network)
+	addrs := w.Addresses()
+	if len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0]
+}
+
+// Addresses returns the address of every output script of the wallet, which
+// might be more than one for descriptors like combo(). Scripts without an
+// address are skipped.
+func (w *Wallet) Addresses() []string {
+	var addrs []string
+	for _, s := range w.scripts {
+		if addr, err := s.EncodeAddress(w.network); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
 }
 
 func (w *Wallet) Path(path string) (*Wallet, error) {
 	return newWallet(w.desc, w.network, path)
 }
+
+// Chains returns a wallet for each chain of a multipath descriptor, e.g. the
+// receive and change chains of `wpkh(xpub/<0;1>/*)`. Wallets without
+// multipath descriptors have a single chain.
+func (w *Wallet) Chains() ([]*Wallet, error) {
+	wallets := make([]*Wallet, len(w.chains))
+	for i, chain := range w.chains {
+		wallet, err := newWallet(chain, w.network, w.path)
+		if err != nil {
+			return nil, err
+		}
+		wallets[i] = wallet
+	}
+	return wallets, nil
+}
@@ -31,3 +31,51 @@ func TestWalletAddrManager(t *testing.T) {
 		})
 	}
 }
+
+func TestWalletAddresses(t *testing.T) {
+	w, err := NewWallet(
+		"combo(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+		script.Mainnet,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+		"3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+	}, w.Addresses())
+	assert.Equal(t, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", w.Address())
+}
+
+func TestWalletChains(t *testing.T) {
+	const xpub = "zpub6u4KbU8TSgNuZSxzv7HaGq5Tk361gMHdZxnM4UYuwzg5CMLcNytzhobitV4Zq6vWtWHpG9QijsigkxAzXvQWyLRfLq1L7VxPP1tky1hPfD4"
+
+	w, err := NewWallet("wpkh([00000000/84'/0'/0']"+xpub+"/<0;1>/*)", script.Mainnet)
+	require.NoError(t, err)
+
+	chains, err := w.Chains()
+	require.NoError(t, err)
+	require.Len(t, chains, 2)
+
+	for i, chain := range chains {
+		single, err := NewWallet(fmt.Sprintf("wpkh([00000000/84'/0'/0']%s/%d/*)", xpub, i), script.Mainnet)
+		require.NoError(t, err)
+
+		for j := 0; j < 3; j++ {
+			path := fmt.Sprintf("m/%d", j)
+			expected, err := single.Path(path)
+			require.NoError(t, err)
+
+			actual, err := chain.Path(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Address(), actual.Address())
+		}
+	}
+
+	// Multipath wallets default to the receive chain.
+	receive, err := chains[0].Path("m/0")
+	require.NoError(t, err)
+	w, err = w.Path("m/0")
+	require.NoError(t, err)
+	assert.Equal(t, receive.Address(), w.Address())
+}